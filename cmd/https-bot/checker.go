@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/reddit/baseplate.go/log"
+
+	"github.com/fishy/https-bot/internal/check"
+	"github.com/fishy/https-bot/similarity"
+)
+
+const (
+	defaultUserAgent    = "httpsbot/1.0 (+https://github.com/fishy/https-bot)"
+	defaultDialTimeout  = time.Second * 5
+	defaultMaxRedirects = 10
+	defaultTLSVersion   = "1.2"
+	defaultSaveInterval = time.Minute * 5
+	defaultMaxWait      = time.Second * 30
+)
+
+func newChecker(cfg config) *check.Checker {
+	comparator, err := similarity.ComparatorByName(cfg.Comparator)
+	if err != nil {
+		log.Fatalw("Invalid comparator", "err", err, "comparator", cfg.Comparator)
+	}
+	checker := &check.Checker{
+		Client:    newClient(cfg),
+		UserAgent: cfg.UserAgent,
+		Headers:   make(http.Header, len(cfg.Headers)),
+		ReadLimit: cfg.Limit,
+		Options: check.Options{
+			Threshold:   *cfg.Threshold,
+			Comparator:  comparator,
+			ExtractText: cfg.ExtractText,
+			TrustText:   cfg.TrustText,
+
+			NormalizeSelfLinks: cfg.Normalize,
+			Calibrate:          cfg.Calibrate,
+			// The scores of the rejected urls are only logged at debug level.
+			FullScores: log.Level(*logLevel) == log.DebugLevel,
+		},
+
+		BlockActiveMixedContent: cfg.BlockActiveMixedContent,
+		AltTLSPorts:             cfg.AltTLSPorts,
+	}
+	if checker.UserAgent == "" {
+		checker.UserAgent = defaultUserAgent
+	}
+	for k, v := range cfg.Headers {
+		checker.Headers.Set(k, v)
+	}
+	if cfg.TLSPolicy.MinVersion == "" {
+		cfg.TLSPolicy.MinVersion = defaultTLSVersion
+	}
+	checker.TLSPolicy = check.TLSPolicy{
+		MinValidity:      time.Duration(cfg.TLSPolicy.MinCertDays) * 24 * time.Hour,
+		RejectSelfSigned: cfg.TLSPolicy.RejectSelfSigned,
+	}
+	checker.TLSPolicy.MinVersion, err = check.ParseTLSVersion(cfg.TLSPolicy.MinVersion)
+	if err != nil {
+		log.Fatalw("Invalid tls policy", "err", err)
+	}
+	for _, name := range cfg.HostVariants {
+		variant, err := check.ParseHostVariant(name)
+		if err != nil {
+			log.Fatalw("Invalid host variant", "err", err)
+		}
+		checker.HostVariants = append(checker.HostVariants, variant)
+	}
+	if cfg.HSTSPreloadList != "" {
+		checker.PreloadList = parsePreloadList(cfg.HSTSPreloadList)
+	}
+	if cfg.RateLimit.MaxWait == 0 {
+		cfg.RateLimit.MaxWait = defaultMaxWait
+	}
+	checker.Limiter = &check.Limiter{
+		Default: cfg.RateLimit.toCheck(),
+		Domains: make(map[string]check.HostLimit, len(cfg.RateLimit.Domains)),
+		MaxWait: cfg.RateLimit.MaxWait,
+	}
+	for domain, limit := range cfg.RateLimit.Domains {
+		checker.Limiter.Domains[domain] = limit.toCheck()
+	}
+	if !cfg.Robots.Disabled {
+		checker.Robots = &check.Robots{
+			TTL:         cfg.Robots.TTL,
+			NegativeTTL: cfg.Robots.NegativeTTL,
+		}
+	}
+	if !cfg.Cache.Disabled {
+		checker.Cache = newCache(cfg)
+	}
+	if cfg.Scrub.Builtin || len(cfg.Scrub.Patterns) > 0 {
+		checker.Scrubber, err = check.NewScrubber(cfg.Scrub.Builtin, cfg.Scrub.Patterns...)
+		if err != nil {
+			log.Fatalw("Invalid scrub config", "err", err)
+		}
+		if cfg.Scrub.Debug {
+			checker.Scrubber.Debug = func(mask check.Mask) {
+				log.Debugw("Masked volatile token", "rule", mask.Rule, "token", mask.Token)
+			}
+		}
+	}
+	return checker
+}
+
+func (l hostLimit) toCheck() check.HostLimit {
+	return check.HostLimit{
+		Rate:          l.Rate,
+		Burst:         l.Burst,
+		MaxConcurrent: l.MaxConcurrent,
+	}
+}
+
+func newCache(cfg config) *check.Cache {
+	cache := &check.Cache{
+		TTL:         cfg.Cache.TTL,
+		NegativeTTL: cfg.Cache.NegativeTTL,
+		MaxEntries:  cfg.Cache.MaxEntries,
+		Path:        cfg.Cache.Path,
+	}
+	if err := cache.Load(); err != nil {
+		// A broken cache file shouldn't stop the bot, it's overwritten on the
+		// next save.
+		log.Errorw("Cannot load cache", "err", err, "path", cfg.Cache.Path)
+	}
+	return cache
+}
+
+// saveCache saves cache to its path every interval until ctx is done.
+//
+// The caller should save it once more after ctx is done.
+func saveCache(ctx context.Context, cache *check.Cache, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultSaveInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := cache.Save(); err != nil {
+				log.Errorw("Cannot save cache", "err", err, "path", cache.Path)
+			}
+		}
+	}
+}
+
+func parsePreloadList(path string) *check.PreloadList {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatalw("Cannot open hsts preload list", "err", err, "path", path)
+	}
+	defer f.Close()
+	list, err := check.ParsePreloadList(f)
+	if err != nil {
+		log.Fatalw("Cannot parse hsts preload list", "err", err, "path", path)
+	}
+	return list
+}
+
+func newAddressGuard(cfg config) *check.AddressGuard {
+	guard := new(check.AddressGuard)
+	for _, cidr := range cfg.Client.AddressGuard.Allow {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.Fatalw("Invalid address guard allowlist", "err", err, "cidr", cidr)
+		}
+		guard.Allow = append(guard.Allow, network)
+	}
+	return guard
+}
+
+func newClient(cfg config) *http.Client {
+	if cfg.Client.DialTimeout <= 0 {
+		cfg.Client.DialTimeout = defaultDialTimeout
+	}
+	if cfg.Client.MaxRedirects <= 0 {
+		cfg.Client.MaxRedirects = defaultMaxRedirects
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:   cfg.Client.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	if !cfg.Client.AddressGuard.Disabled {
+		dialer.Control = newAddressGuard(cfg).Control
+	}
+	transport.DialContext = dialer.DialContext
+	if cfg.Client.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = cfg.Client.TLSHandshakeTimeout
+	}
+	if cfg.Client.Proxy != "" {
+		proxy, err := url.Parse(cfg.Client.Proxy)
+		if err != nil {
+			log.Fatalw("Invalid proxy url", "err", err, "proxy", cfg.Client.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	maxRedirects := cfg.Client.MaxRedirects
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return nil
+		},
+	}
+}
@@ -34,6 +34,15 @@ func hnMain(ctx context.Context, wg *sync.WaitGroup, cfg config) {
 	if cfg.HN.Workers <= 0 {
 		cfg.HN.Workers = defaultHNWorkers
 	}
+	checker := newChecker(cfg)
+	if checker.Cache != nil && checker.Cache.Path != "" {
+		go saveCache(ctx, checker.Cache, cfg.Cache.SaveInterval)
+		defer func() {
+			if err := checker.Cache.Save(); err != nil {
+				log.Errorw("Cannot save cache", "err", err, "path", checker.Cache.Path)
+			}
+		}()
+	}
 	session := func(ctx context.Context) *hnapi.Session {
 		ctx, cancel := context.WithTimeout(ctx, cfg.HN.Timeout)
 		defer cancel()
@@ -46,7 +55,7 @@ func hnMain(ctx context.Context, wg *sync.WaitGroup, cfg config) {
 
 	c := make(chan int64)
 	for i := 0; i < cfg.HN.Workers; i++ {
-		go hnWorker(ctx, wg, session, cfg, c)
+		go hnWorker(ctx, wg, session, cfg, checker, c)
 	}
 
 	if cfg.HN.Interval <= 0 {
@@ -111,10 +120,12 @@ func hnMain(ctx context.Context, wg *sync.WaitGroup, cfg config) {
 
 type result struct {
 	oldURL, newURL string
-	similarity     float64
+	similarity     float64 // Scores.Raw, not the calibrated one
+	outcome        check.Outcome
+	hsts           *check.HSTS
 }
 
-func hnWorker(ctx context.Context, wg *sync.WaitGroup, session *hnapi.Session, cfg config, c <-chan int64) {
+func hnWorker(ctx context.Context, wg *sync.WaitGroup, session *hnapi.Session, cfg config, checker *check.Checker, c <-chan int64) {
 	defer wg.Done()
 
 	self := strings.ToLower(cfg.HN.Username)
@@ -151,20 +162,55 @@ func hnWorker(ctx context.Context, wg *sync.WaitGroup, session *hnapi.Session, c
 					r := func(ctx context.Context, url string) *result {
 						ctx, cancel := context.WithTimeout(ctx, cfg.HN.Timeout)
 						defer cancel()
-						newURL, sim, err := check.Check(ctx, url, cfg.Limit, nil)
+						res, err := checker.Check(ctx, url)
+						raw, normalized := logScores(res.Scores)
 						if err != nil {
-							if !errors.Is(err, check.ErrNotHTTP) {
-								log.Infow("Check failed", "err", err, "url", url)
+							switch {
+							case errors.Is(err, check.ErrNotHTTP):
+							case errors.Is(err, check.ErrTooDissimilar),
+								errors.Is(err, check.ErrDowngrades),
+								errors.Is(err, check.ErrHostMismatch),
+								errors.Is(err, check.ErrTLSPolicy),
+								errors.Is(err, check.ErrMixedContent),
+								errors.Is(err, check.ErrContentTypeMismatch):
+								log.Debugw(
+									"Check failed",
+									"err", err,
+									"url", url,
+									"raw", raw,
+									"normalized", normalized,
+									"result", res,
+								)
+							case errors.Is(err, check.ErrDisallowedByRobots):
+								log.Debugw("Check skipped by robots.txt", "err", err, "url", url)
+							case errors.Is(err, check.ErrRateLimited):
+								log.Infow("Check skipped by rate limit", "err", err, "url", url)
+							default:
+								log.Infow(
+									"Check failed",
+									"err", err,
+									"category", check.Category(err),
+									"url", url,
+									"raw", raw,
+									"normalized", normalized,
+									"result", res,
+								)
 							}
 							return nil
 						}
-						if sim < *cfg.Threshold {
-							return nil
-						}
+						log.Debugw(
+							"Check succeeded",
+							"url", url,
+							"raw", raw,
+							"normalized", normalized,
+							"result", res,
+						)
 						return &result{
 							oldURL:     url,
-							newURL:     newURL,
-							similarity: sim,
+							newURL:     res.HTTPSURL,
+							similarity: res.Scores.Raw,
+							outcome:    res.Outcome,
+							hsts:       res.HSTS,
 						}
 					}(ctx, url)
 					if r != nil {
@@ -195,13 +241,22 @@ func hnWorker(ctx context.Context, wg *sync.WaitGroup, session *hnapi.Session, c
 							"parent", fmt.Sprintf("https://news.ycombinator.com/item?id=%d", item.ID),
 						)
 					}
-				}(ctx, hnMessage(results))
+				}(ctx, hnMessage(results, cfg.HN.MentionHSTS))
 			}(i)
 		}
 	}
 }
 
-func hnMessage(results []*result) string {
+// logScores returns the raw and normalized similarities to log,
+// which are "unknown" when they are not computed.
+func logScores(scores check.Scores) (raw, normalized interface{}) {
+	if scores.Kind == "" || scores.Partial {
+		return "unknown", "unknown"
+	}
+	return scores.Raw, scores.Similarity
+}
+
+func hnMessage(results []*result, mentionHSTS bool) string {
 	var sb strings.Builder
 	for _, r := range results {
 		sb.WriteString(fmt.Sprintf(
@@ -210,6 +265,24 @@ func hnMessage(results []*result) string {
 			r.oldURL,
 			r.similarity*100,
 		))
+		if r.outcome == check.OutcomeAlreadyUpgrades {
+			sb.WriteString(fmt.Sprintf(
+				"(%s already redirects to HTTPS, but that first request is still insecure.)\n\n",
+				r.oldURL,
+			))
+		}
+		if mentionHSTS && r.hsts != nil {
+			switch {
+			case r.hsts.Preloaded:
+				sb.WriteString(
+					"(Its domain is on the HSTS preload list, but not every browser uses that list.)\n\n",
+				)
+			case r.hsts.MaxAge > 0:
+				sb.WriteString(
+					"(The HTTPS version sets HSTS, but that only protects the visits after the first one.)\n\n",
+				)
+			}
+		}
 	}
 	sb.WriteString(
 		`(I'm a bot, see https://github.com/fishy/https-bot for source code and FAQ)`,
@@ -33,8 +33,83 @@ const (
 )
 
 type config struct {
-	Threshold *float64 `yaml:"similarity_threshold"`
-	Limit     int64    `yaml:"read_limit"`
+	Threshold   *float64 `yaml:"similarity_threshold"`
+	Limit       int64    `yaml:"read_limit"`
+	Comparator  string   `yaml:"comparator"`
+	ExtractText bool     `yaml:"extract_text"`
+	TrustText   bool     `yaml:"trust_text"`
+	Normalize   bool     `yaml:"normalize_self_links"`
+	Calibrate   bool     `yaml:"calibrate"`
+
+	Scrub struct {
+		Builtin  bool     `yaml:"builtin"`
+		Patterns []string `yaml:"patterns"`
+		Debug    bool     `yaml:"debug"`
+	} `yaml:"scrub"`
+
+	// Path to a copy of Chromium's HSTS preload list,
+	// e.g. the one "make update-hsts-preload" downloads.
+	// Without it no host is considered preloaded.
+	HSTSPreloadList string `yaml:"hsts_preload_list"`
+
+	TLSPolicy struct {
+		// Defaults to "1.2".
+		MinVersion       string `yaml:"min_version"`
+		MinCertDays      int    `yaml:"min_cert_days"`
+		RejectSelfSigned bool   `yaml:"reject_self_signed"`
+	} `yaml:"tls_policy"`
+
+	BlockActiveMixedContent bool     `yaml:"block_active_mixed_content"`
+	AltTLSPorts             []string `yaml:"alt_tls_ports"`
+	HostVariants            []string `yaml:"host_variants"`
+
+	Cache struct {
+		Disabled    bool          `yaml:"disabled"`
+		TTL         time.Duration `yaml:"ttl"`
+		NegativeTTL time.Duration `yaml:"negative_ttl"`
+		MaxEntries  int           `yaml:"max_entries"`
+		// When non-empty, the cache is persisted to this file.
+		Path         string        `yaml:"path"`
+		SaveInterval time.Duration `yaml:"save_interval"`
+	} `yaml:"cache"`
+
+	Robots struct {
+		// Set to true to ignore robots.txt.
+		Disabled bool          `yaml:"disabled"`
+		TTL      time.Duration `yaml:"ttl"`
+		// How long to keep robots.txt files failed with 5xx statuses.
+		NegativeTTL time.Duration `yaml:"negative_ttl"`
+	} `yaml:"robots"`
+
+	RateLimit struct {
+		hostLimit `yaml:",inline"`
+
+		// Per-domain overrides, applied to their subdomains too.
+		Domains map[string]hostLimit `yaml:"domains"`
+		// How long a request queues for its host's budget before the check is
+		// skipped.
+		// Defaults to 30s, negative values skip without queueing.
+		MaxWait time.Duration `yaml:"max_wait"`
+	} `yaml:"rate_limit"`
+
+	UserAgent string            `yaml:"user_agent"`
+	Headers   map[string]string `yaml:"headers"`
+
+	Client struct {
+		DialTimeout         time.Duration `yaml:"dial_timeout"`
+		TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout"`
+		Proxy               string        `yaml:"proxy"`
+		MaxRedirects        int           `yaml:"max_redirects"`
+
+		// Refuses to connect to private, loopback, link-local and cloud
+		// metadata addresses.
+		AddressGuard struct {
+			Disabled bool `yaml:"disabled"`
+			// CIDRs allowed even though they are blocked,
+			// e.g. the address of the proxy.
+			Allow []string `yaml:"allow"`
+		} `yaml:"address_guard"`
+	} `yaml:"client"`
 
 	HN struct {
 		Username          string        `yaml:"username"`
@@ -44,9 +119,17 @@ type config struct {
 		Interval          time.Duration `yaml:"interval"`
 		Workers           int           `yaml:"workers"`
 		TickLogSampleRate float64       `yaml:"tick_log_sample_rate"`
+		MentionHSTS       bool          `yaml:"mention_hsts"`
 	} `yaml:"hn"`
 }
 
+type hostLimit struct {
+	// Requests per second, unlimited when not positive.
+	Rate          float64 `yaml:"rate"`
+	Burst         int     `yaml:"burst"`
+	MaxConcurrent int     `yaml:"max_concurrent"`
+}
+
 func main() {
 	flag.Parse()
 	log.InitLogger(log.Level(*logLevel))
@@ -1,9 +1,27 @@
 module github.com/fishy/https-bot
 
-go 1.16
+go 1.17
 
 require (
+	github.com/andybalholm/brotli v1.0.2
 	github.com/reddit/baseplate.go v0.8.1
-	golang.org/x/net v0.0.0-20210331212208-0fccb6fa2b5c
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.13.0
 	gopkg.in/yaml.v2 v2.4.0
 )
+
+require (
+	github.com/VividCortex/gohistogram v1.0.0 // indirect
+	github.com/apache/thrift v0.14.1 // indirect
+	github.com/getsentry/sentry-go v0.6.0 // indirect
+	github.com/go-kit/kit v0.9.0 // indirect
+	github.com/go-logfmt/logfmt v0.4.0 // indirect
+	github.com/gofrs/uuid v3.2.0+incompatible // indirect
+	github.com/kr/logfmt v0.0.0-20140226030751-b84e30acd515 // indirect
+	github.com/opentracing/opentracing-go v1.1.0 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
+	go.uber.org/multierr v1.5.0 // indirect
+	go.uber.org/zap v1.15.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	gopkg.in/fsnotify.v1 v1.4.7 // indirect
+)
@@ -0,0 +1,424 @@
+package check
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default values used by Cache when the corresponding fields are not
+// positive.
+const (
+	DefaultCacheTTL         = 6 * time.Hour
+	DefaultNegativeCacheTTL = 30 * time.Minute
+	DefaultCacheMaxEntries  = 10000
+)
+
+// hostCategories are the categories of errors that mean the https side of the
+// whole host doesn't work,
+// instead of just the url being checked.
+var hostCategories = []error{
+	ErrDNS,
+	ErrBlockedAddress,
+	ErrConnectionRefused,
+	ErrTLSHandshake,
+	ErrCertHostname,
+	ErrCertExpired,
+}
+
+// Cache caches the results of Checker.Check,
+// keyed by the normalized urls.
+//
+// It also remembers whether https works on every host,
+// so that when https on a host fails for one url,
+// the other urls on the same host are not fetched until the negative result
+// expires.
+//
+// The zero value is an in-memory cache with the default configurations.
+// A Cache is safe for concurrent use,
+// as long as its fields are not modified after the first use.
+type Cache struct {
+	// The time to keep the successful results.
+	//
+	// When it's not positive, DefaultCacheTTL is used.
+	TTL time.Duration
+
+	// The time to keep the failed results.
+	//
+	// When it's not positive, DefaultNegativeCacheTTL is used.
+	NegativeTTL time.Duration
+
+	// The max number of urls and hosts to keep,
+	// the least recently used ones are evicted first.
+	//
+	// When it's not positive, DefaultCacheMaxEntries is used.
+	MaxEntries int
+
+	// When Path is non-empty, Load and Save persist the cache to this file.
+	Path string
+
+	mu    sync.Mutex
+	urls  *lru
+	hosts *lru
+
+	// For tests.
+	now func() time.Time
+}
+
+// cacheEntry is an entry of the cache, either for a url or a host.
+type cacheEntry struct {
+	key     string
+	result  *Result
+	err     error
+	expires time.Time
+}
+
+// lru is a map with its entries in the order of the last access.
+type lru struct {
+	order *list.List // of *cacheEntry, the most recently used first
+	index map[string]*list.Element
+}
+
+func newLRU() *lru {
+	return &lru{
+		order: list.New(),
+		index: make(map[string]*list.Element),
+	}
+}
+
+func (l *lru) get(key string, now time.Time) *cacheEntry {
+	elem, ok := l.index[key]
+	if !ok {
+		return nil
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !now.Before(entry.expires) {
+		l.order.Remove(elem)
+		delete(l.index, key)
+		return nil
+	}
+	l.order.MoveToFront(elem)
+	return entry
+}
+
+func (l *lru) put(entry *cacheEntry, max int) {
+	if elem, ok := l.index[entry.key]; ok {
+		elem.Value = entry
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.index[entry.key] = l.order.PushFront(entry)
+	for l.order.Len() > max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.index, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *Cache) init() {
+	if c.urls == nil {
+		c.urls = newLRU()
+		c.hosts = newLRU()
+	}
+}
+
+func (c *Cache) timeNow() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+func (c *Cache) ttl() time.Duration {
+	if c.TTL > 0 {
+		return c.TTL
+	}
+	return DefaultCacheTTL
+}
+
+func (c *Cache) negativeTTL() time.Duration {
+	if c.NegativeTTL > 0 {
+		return c.NegativeTTL
+	}
+	return DefaultNegativeCacheTTL
+}
+
+func (c *Cache) maxEntries() int {
+	if c.MaxEntries > 0 {
+		return c.MaxEntries
+	}
+	return DefaultCacheMaxEntries
+}
+
+// cacheKey returns the normalized form of the http url u as the cache key.
+//
+// The scheme and host are lowercased,
+// the default port and the tracking parameters are dropped.
+func cacheKey(u *url.URL) string {
+	key := *dropDefaultPort(u)
+	key.Scheme = strings.ToLower(key.Scheme)
+	key.Host = strings.ToLower(key.Host)
+	key.RawQuery = stripTracking(key.RawQuery)
+	key.ForceQuery = false
+	return key.String()
+}
+
+// HostWorks reports whether https was known to work on host recently.
+//
+// known is false when there's no unexpired memory of host.
+func (c *Cache) HostWorks(host string) (works, known bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	entry := c.hosts.get(strings.ToLower(host), c.timeNow())
+	if entry == nil {
+		return false, false
+	}
+	return entry.err == nil, true
+}
+
+// lookup returns the cached result and error of the http url u.
+//
+// The returned result is a copy with Cached set to true.
+func (c *Cache) lookup(u *url.URL) (*Result, error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	now := c.timeNow()
+	if entry := c.urls.get(cacheKey(u), now); entry != nil {
+		result := *entry.result
+		result.URL = u.String()
+		result.Cached = true
+		return &result, entry.err, true
+	}
+	if entry := c.hosts.get(strings.ToLower(u.Hostname()), now); entry != nil && entry.err != nil {
+		return &Result{
+			URL:    u.String(),
+			Cached: true,
+		}, fmt.Errorf("https on host %q failed recently: %w", u.Hostname(), entry.err), true
+	}
+	return nil, nil, false
+}
+
+// store caches the result and error of the http url u.
+//
+// The host is only remembered as failing when httpsFailed is true,
+// as the errors of the http url say nothing about https on the host.
+func (c *Cache) store(u *url.URL, result *Result, err error, httpsFailed bool) {
+	if err != nil {
+		err = newCachedError(err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	now := c.timeNow()
+	ttl := c.ttl()
+	if err != nil {
+		ttl = c.negativeTTL()
+	}
+	stored := *result
+	stored.Cached = false
+	c.urls.put(&cacheEntry{
+		key:     cacheKey(u),
+		result:  &stored,
+		err:     err,
+		expires: now.Add(ttl),
+	}, c.maxEntries())
+
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case err == nil:
+		c.hosts.put(&cacheEntry{
+			key:     host,
+			expires: now.Add(ttl),
+		}, c.maxEntries())
+	case httpsFailed && isHostError(err):
+		c.hosts.put(&cacheEntry{
+			key:     host,
+			err:     err,
+			expires: now.Add(ttl),
+		}, c.maxEntries())
+	}
+}
+
+func isHostError(err error) bool {
+	for _, category := range hostCategories {
+		if errors.Is(err, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedError is an error restored from the cache.
+//
+// It keeps the message and the category of the original error,
+// so that it still matches the category with errors.Is.
+type cachedError struct {
+	msg      string
+	category error
+}
+
+func newCachedError(err error) *cachedError {
+	var cached *cachedError
+	if errors.As(err, &cached) {
+		return cached
+	}
+	return &cachedError{
+		msg:      err.Error(),
+		category: Category(err),
+	}
+}
+
+func (e *cachedError) Error() string {
+	return e.msg
+}
+
+func (e *cachedError) Is(target error) bool {
+	return e.category != nil && target == e.category
+}
+
+// categoryByName returns the category with the message name,
+// or nil if there's no such category.
+func categoryByName(name string) error {
+	for _, c := range categories {
+		if c.Error() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// cacheRecord is the persisted form of a cacheEntry.
+type cacheRecord struct {
+	Key      string    `json:"key"`
+	Result   *Result   `json:"result,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Category string    `json:"category,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+type cacheFile struct {
+	URLs  []cacheRecord `json:"urls"`
+	Hosts []cacheRecord `json:"hosts"`
+}
+
+func (l *lru) records(now time.Time) []cacheRecord {
+	records := make([]cacheRecord, 0, l.order.Len())
+	// From the least recently used, so that they are loaded in the same order.
+	for elem := l.order.Back(); elem != nil; elem = elem.Prev() {
+		entry := elem.Value.(*cacheEntry)
+		if !now.Before(entry.expires) {
+			continue
+		}
+		record := cacheRecord{
+			Key:     entry.key,
+			Result:  entry.result,
+			Expires: entry.expires,
+		}
+		if entry.err != nil {
+			record.Error = entry.err.Error()
+			if category := Category(entry.err); category != nil {
+				record.Category = category.Error()
+			}
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func (l *lru) load(records []cacheRecord, now time.Time, max int) {
+	for _, record := range records {
+		if !now.Before(record.Expires) {
+			continue
+		}
+		entry := &cacheEntry{
+			key:     record.Key,
+			result:  record.Result,
+			expires: record.Expires,
+		}
+		if record.Error != "" {
+			entry.err = &cachedError{
+				msg:      record.Error,
+				category: categoryByName(record.Category),
+			}
+		}
+		l.put(entry, max)
+	}
+}
+
+// Load loads the unexpired entries persisted in Path into the cache.
+//
+// It's a no-op when Path is empty or doesn't exist yet.
+func (c *Cache) Load() error {
+	if c.Path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(c.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cache file %q: %w", c.Path, err)
+	}
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse cache file %q: %w", c.Path, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	now := c.timeNow()
+	c.urls.load(file.URLs, now, c.maxEntries())
+	c.hosts.load(file.Hosts, now, c.maxEntries())
+	return nil
+}
+
+// Save persists the unexpired entries of the cache into Path.
+//
+// The file is replaced atomically.
+// It's a no-op when Path is empty.
+func (c *Cache) Save() error {
+	if c.Path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	c.init()
+	now := c.timeNow()
+	file := cacheFile{
+		URLs:  c.urls.records(now),
+		Hosts: c.hosts.records(now),
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to encode cache: %w", err)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.Path), filepath.Base(c.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cache file %q: %w", tmp.Name(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cache file %q: %w", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), c.Path); err != nil {
+		return fmt.Errorf("failed to replace cache file %q: %w", c.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,291 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// countingHandler counts the requests to handler in n.
+func countingHandler(n *int32, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(n, 1)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// fakeClock is a manually advanced clock for Cache.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func TestCacheKey(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+	}{
+		{"http://Example.COM/foo", "http://example.com/foo"},
+		{"http://example.com:80/foo", "http://example.com/foo"},
+		{"http://example.com/foo?utm_source=hn&a=1", "http://example.com/foo?a=1"},
+		{"http://example.com/foo?", "http://example.com/foo"},
+	} {
+		a, _ := url.Parse(c.a)
+		b, _ := url.Parse(c.b)
+		if cacheKey(a) != cacheKey(b) {
+			t.Errorf("cacheKey(%q) = %q, cacheKey(%q) = %q", c.a, cacheKey(a), c.b, cacheKey(b))
+		}
+	}
+	for _, c := range []struct {
+		a, b string
+	}{
+		{"http://example.com/foo", "http://example.com/Foo"},
+		{"http://example.com:8080/foo", "http://example.com/foo"},
+		{"http://example.com/foo?a=1", "http://example.com/foo?a=2"},
+	} {
+		a, _ := url.Parse(c.a)
+		b, _ := url.Parse(c.b)
+		if cacheKey(a) == cacheKey(b) {
+			t.Errorf("cacheKey(%q) == cacheKey(%q) = %q", c.a, c.b, cacheKey(a))
+		}
+	}
+}
+
+func TestCheckerCache(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+
+	t.Run("hit", func(t *testing.T) {
+		var n int32
+		checker := newTestChecker(
+			t,
+			countingHandler(&n, staticHandler(content)),
+			countingHandler(&n, staticHandler(content)),
+		)
+		checker.Cache = &Cache{}
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Cached {
+			t.Error("Expected the first result not cached")
+		}
+		fetches := atomic.LoadInt32(&n)
+
+		result, err = checker.Check(context.Background(), "http://"+testHost+"/foo?utm_source=hn")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Cached {
+			t.Error("Expected the second result cached")
+		}
+		if result.URL != "http://"+testHost+"/foo?utm_source=hn" {
+			t.Errorf("Expected the url checked, got %q", result.URL)
+		}
+		if result.HTTPSURL != "https://"+testHost+"/foo" {
+			t.Errorf("Expected the https url cached, got %q", result.HTTPSURL)
+		}
+		if got := atomic.LoadInt32(&n); got != fetches {
+			t.Errorf("Expected no more fetches than %d, got %d", fetches, got)
+		}
+		if works, known := checker.Cache.HostWorks(testHost); !works || !known {
+			t.Errorf("HostWorks = %v, %v, expected true, true", works, known)
+		}
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		var n int32
+		clock := &fakeClock{t: time.Now()}
+		checker := newTestChecker(
+			t,
+			countingHandler(&n, staticHandler(content)),
+			countingHandler(&n, staticHandler("<p>Something else entirely.</p>")),
+		)
+		checker.Threshold = 0.95
+		checker.Cache = &Cache{
+			TTL:         time.Hour,
+			NegativeTTL: time.Minute,
+			now:         clock.now,
+		}
+		_, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+		if !errors.Is(err, ErrTooDissimilar) {
+			t.Fatalf("Expected %v, got %v", ErrTooDissimilar, err)
+		}
+		fetches := atomic.LoadInt32(&n)
+
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+		if !errors.Is(err, ErrTooDissimilar) {
+			t.Errorf("Expected cached %v, got %v", ErrTooDissimilar, err)
+		}
+		if !result.Cached {
+			t.Error("Expected the result cached")
+		}
+		if got := atomic.LoadInt32(&n); got != fetches {
+			t.Errorf("Expected no more fetches than %d, got %d", fetches, got)
+		}
+		// Dissimilar contents say nothing about the host.
+		if _, known := checker.Cache.HostWorks(testHost); known {
+			t.Error("Expected the host unknown")
+		}
+
+		clock.t = clock.t.Add(2 * time.Minute)
+		result, _ = checker.Check(context.Background(), "http://"+testHost+"/foo")
+		if result.Cached {
+			t.Error("Expected the negative result expired")
+		}
+		if got := atomic.LoadInt32(&n); got == fetches {
+			t.Error("Expected the url fetched again")
+		}
+	})
+
+	t.Run("host", func(t *testing.T) {
+		var n int32
+		const host = "example.net" // not covered by the test certificate
+		checker := newTestChecker(
+			t,
+			countingHandler(&n, staticHandler(content)),
+			countingHandler(&n, staticHandler(content)),
+		)
+		checker.Cache = &Cache{}
+		_, err := checker.Check(context.Background(), "http://"+host+"/foo")
+		if !errors.Is(err, ErrCertHostname) {
+			t.Fatalf("Expected %v, got %v", ErrCertHostname, err)
+		}
+		if works, known := checker.Cache.HostWorks(host); works || !known {
+			t.Errorf("HostWorks = %v, %v, expected false, true", works, known)
+		}
+		fetches := atomic.LoadInt32(&n)
+
+		result, err := checker.Check(context.Background(), "http://"+host+"/bar")
+		if !errors.Is(err, ErrCertHostname) {
+			t.Errorf("Expected cached %v, got %v", ErrCertHostname, err)
+		}
+		if !result.Cached {
+			t.Error("Expected the result cached")
+		}
+		if got := atomic.LoadInt32(&n); got != fetches {
+			t.Errorf("Expected no more fetches than %d, got %d", fetches, got)
+		}
+	})
+
+	t.Run("http-host", func(t *testing.T) {
+		checker := newTestChecker(t, staticHandler(content), staticHandler(content))
+		transport := checker.Client.Transport.(*http.Transport)
+		dial := transport.DialContext
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if _, port, _ := net.SplitHostPort(addr); port == "80" {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+			}
+			return dial(ctx, network, addr)
+		}
+		checker.Cache = &Cache{}
+		_, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+		if !errors.Is(err, ErrConnectionRefused) {
+			t.Fatalf("Expected %v, got %v", ErrConnectionRefused, err)
+		}
+		// It's the http url that failed, not https on the host.
+		if works, known := checker.Cache.HostWorks(testHost); works || known {
+			t.Errorf("HostWorks = %v, %v, expected false, false", works, known)
+		}
+		u, _ := url.Parse("http://" + testHost + "/bar")
+		if _, _, ok := checker.Cache.lookup(u); ok {
+			t.Error("Expected other urls on the host not cached")
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		checker := newTestChecker(t, staticHandler(content), staticHandler(content))
+		checker.Cache = &Cache{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := checker.Check(ctx, "http://"+testHost+"/foo"); err == nil {
+			t.Fatal("Expected error")
+		}
+		u, _ := url.Parse("http://" + testHost + "/foo")
+		if _, _, ok := checker.Cache.lookup(u); ok {
+			t.Error("Expected errors caused by the context not cached")
+		}
+	})
+}
+
+func TestCacheMaxEntries(t *testing.T) {
+	cache := &Cache{MaxEntries: 2}
+	urls := make([]*url.URL, 3)
+	for i := range urls {
+		urls[i], _ = url.Parse("http://example.com/" + string(rune('a'+i)))
+	}
+	cache.store(urls[0], &Result{}, nil, false)
+	cache.store(urls[1], &Result{}, nil, false)
+	// Make urls[1] the least recently used.
+	cache.lookup(urls[0])
+	cache.store(urls[2], &Result{}, nil, false)
+
+	for i, expected := range []bool{true, false, true} {
+		if _, _, ok := cache.lookup(urls[i]); ok != expected {
+			t.Errorf("lookup(%q) = %v, expected %v", urls[i], ok, expected)
+		}
+	}
+}
+
+func TestCachePersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.json")
+	clock := &fakeClock{t: time.Now()}
+	ok, _ := url.Parse("http://example.com/ok")
+	bad, _ := url.Parse("http://example.com/bad")
+	down, _ := url.Parse("http://down.example.com/foo")
+	other, _ := url.Parse("http://down.example.com/bar")
+
+	cache := &Cache{Path: path, now: clock.now}
+	if err := cache.Load(); err != nil {
+		t.Fatalf("Expected missing file ignored, got %v", err)
+	}
+	cache.store(ok, &Result{HTTPSURL: "https://example.com/ok", Outcome: OutcomeUpgradable}, nil, false)
+	cache.store(bad, &Result{}, ErrTooDissimilar, true)
+	cache.store(down, &Result{}, fmt.Errorf("failed to fetch: %w", ErrConnectionRefused), true)
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &Cache{Path: path, now: clock.now}
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	result, err, hit := loaded.lookup(ok)
+	if !hit || err != nil {
+		t.Fatalf("lookup(%q) = %v, %v, expected a cached success", ok, err, hit)
+	}
+	if result.HTTPSURL != "https://example.com/ok" || result.Outcome != OutcomeUpgradable {
+		t.Errorf("Expected the result restored, got %+v", result)
+	}
+	if _, err, _ := loaded.lookup(bad); !errors.Is(err, ErrTooDissimilar) {
+		t.Errorf("Expected %v restored, got %v", ErrTooDissimilar, err)
+	}
+	if _, err, _ := loaded.lookup(other); !errors.Is(err, ErrConnectionRefused) {
+		t.Errorf("Expected host error %v restored, got %v", ErrConnectionRefused, err)
+	}
+
+	// The negative results expire first.
+	clock.t = clock.t.Add(DefaultNegativeCacheTTL)
+	if err := loaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := &Cache{Path: path, now: clock.now}
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, hit := reloaded.lookup(bad); hit {
+		t.Error("Expected the expired entry not persisted")
+	}
+	if _, _, hit := reloaded.lookup(ok); !hit {
+		t.Error("Expected the unexpired entry persisted")
+	}
+}
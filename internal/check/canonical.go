@@ -0,0 +1,94 @@
+package check
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trackingParams are the query parameters known to be only used for tracking,
+// which are stripped from the recommended https url.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"gclsrc":  true,
+	"msclkid": true,
+	"yclid":   true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"igshid":  true,
+	"_ga":     true,
+	"_gl":     true,
+	"_hsenc":  true,
+	"_hsmi":   true,
+}
+
+// trackingPrefixes are the prefixes of the query parameter names known to be
+// only used for tracking.
+var trackingPrefixes = []string{
+	"utm_",
+	"pk_",
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	if trackingParams[name] {
+		return true
+	}
+	for _, prefix := range trackingPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripTracking returns rawQuery with the tracking parameters removed.
+//
+// The rest of the parameters are kept as-is and in order,
+// instead of being re-encoded.
+func stripTracking(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	for _, param := range params {
+		name := param
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name = param[:i]
+		}
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if !isTrackingParam(name) {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// canonicalURL returns final with the tracking parameters stripped from the
+// query and the default port dropped.
+//
+// stripped reports whether any tracking parameter was stripped,
+// in which case the returned url is not the one actually fetched.
+func canonicalURL(final *url.URL) (canonical *url.URL, stripped bool) {
+	u := *dropDefaultPort(final)
+	u.RawQuery = stripTracking(final.RawQuery)
+	u.ForceQuery = false
+	return &u, u.RawQuery != final.RawQuery
+}
+
+// withFragment returns u with the fragment of original carried over when u
+// doesn't have one,
+// as browsers do when following redirects.
+func withFragment(u, original *url.URL) *url.URL {
+	if u.Fragment != "" {
+		return u
+	}
+	clone := *u
+	clone.Fragment = original.Fragment
+	clone.RawFragment = original.RawFragment
+	return &clone
+}
@@ -0,0 +1,98 @@
+package check
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestStripTracking(t *testing.T) {
+	for _, c := range []struct {
+		query    string
+		expected string
+	}{
+		{
+			query:    "",
+			expected: "",
+		},
+		{
+			query:    "id=1",
+			expected: "id=1",
+		},
+		{
+			query:    "utm_source=hn&id=1&utm_medium=social",
+			expected: "id=1",
+		},
+		{
+			query:    "b=2&fbclid=abc&a=1",
+			expected: "b=2&a=1",
+		},
+		{
+			query:    "UTM_Campaign=x&GCLID=y",
+			expected: "",
+		},
+		{
+			query:    "utm%5Fsource=hn&q=a%20b",
+			expected: "q=a%20b",
+		},
+		{
+			query:    "flag&utm_source",
+			expected: "flag",
+		},
+	} {
+		t.Run(c.query, func(t *testing.T) {
+			if actual := stripTracking(c.query); actual != c.expected {
+				t.Errorf("stripTracking(%q) expected %q, got %q", c.query, c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	for _, c := range []struct {
+		final    string
+		original string
+		expected string
+		stripped bool
+	}{
+		{
+			final:    "https://example.com/foo/",
+			original: "http://example.com/foo",
+			expected: "https://example.com/foo/",
+		},
+		{
+			final:    "https://www.example.com/foo?id=1&utm_source=hn",
+			original: "http://example.com/foo?id=1&utm_source=hn#bar",
+			expected: "https://www.example.com/foo?id=1#bar",
+			stripped: true,
+		},
+		{
+			final:    "https://example.com/foo?utm_source=hn",
+			original: "http://example.com/foo?utm_source=hn",
+			expected: "https://example.com/foo",
+			stripped: true,
+		},
+		{
+			final:    "https://example.com/new#top",
+			original: "http://example.com/old#bar",
+			expected: "https://example.com/new#top",
+		},
+	} {
+		t.Run(c.final, func(t *testing.T) {
+			final, err := url.Parse(c.final)
+			if err != nil {
+				t.Fatal(err)
+			}
+			original, err := url.Parse(c.original)
+			if err != nil {
+				t.Fatal(err)
+			}
+			canonical, stripped := canonicalURL(final)
+			if stripped != c.stripped {
+				t.Errorf("Expected stripped to be %v, got %v", c.stripped, stripped)
+			}
+			if actual := withFragment(canonical, original).String(); actual != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
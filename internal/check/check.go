@@ -2,52 +2,466 @@ package check
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/reddit/baseplate.go/httpbp"
 
 	"github.com/fishy/https-bot/similarity"
 )
 
-// Common errors
-var (
-	ErrNotHTTP = errors.New("not an http url")
-)
+// DefaultReadLimit is the read limit used when Checker.ReadLimit is not
+// positive.
+const DefaultReadLimit = 1024 * 10
+
+var defaultClient = http.Client{
+	Transport: guardedTransport(&AddressGuard{}),
+}
+
+// Checker checks whether http urls can be safely replaced by https urls.
+//
+// A Checker is safe for concurrent use,
+// as long as it's not modified after the first Check call.
+type Checker struct {
+	// The http client used to fetch the urls.
+	//
+	// When it's nil, a client refusing the addresses blocked by a zero value
+	// AddressGuard is used.
+	// Custom clients should use AddressGuard in their dialers unless all the
+	// urls are trusted.
+	Client *http.Client
+
+	// The user agent to send with every request.
+	//
+	// When it's non-empty, it overrides the user-agent in Headers.
+	UserAgent string
+
+	// Headers to send with every request.
+	Headers http.Header
+
+	// The max number of bytes to read from every response.
+	//
+	// When it's not positive, DefaultReadLimit is used.
+	ReadLimit int64
+
+	// The alternate ports to try https on,
+	// in order, when the default https port (443) fails.
+	AltTLSPorts []string
+
+	// The host variants to try in order,
+	// when the https url on the original host fails.
+	//
+	// Every variant goes through the same checks as the original host.
+	HostVariants []HostVariant
+
+	// The policy the TLS connection of the https url must meet.
+	TLSPolicy TLSPolicy
+
+	// When BlockActiveMixedContent is true,
+	// https pages loading active content over http are not recommended.
+	BlockActiveMixedContent bool
+
+	// The HSTS preload list the http urls are checked against.
+	//
+	// When it's nil, no host is considered preloaded.
+	PreloadList *PreloadList
+
+	// When Cache is non-nil, the results are cached in it,
+	// and the urls with unexpired results are not fetched again.
+	Cache *Cache
+
+	// When Limiter is non-nil, the requests to every host are limited by it.
+	Limiter *Limiter
+
+	// When Robots is non-nil,
+	// the urls disallowed by their robots.txt are not fetched,
+	// and the crawl-delays are honored.
+	Robots *Robots
+
+	// The options to compare the contents.
+	Options
+}
+
+// Options are the optional configurations for comparing the contents.
+type Options struct {
+	// The minimal similarity required between the contents of http and https
+	// urls.
+	//
+	// When it's non-zero, Check returns ErrTooDissimilar as soon as it's
+	// provably out of reach, without computing the full similarity,
+	// unless FullScores is true.
+	Threshold float64
+
+	// The comparator used to compute the similarity.
+	//
+	// The zero value compares the contents byte by byte.
+	Comparator similarity.Comparator
+
+	// When ExtractText is true, Check also compares the document titles and
+	// visible texts of the contents,
+	// ignoring scripts, styles and comments.
+	ExtractText bool
+
+	// When TrustText is true, Threshold is applied to the similarity between
+	// the texts instead of the raw contents.
+	//
+	// It's ignored when ExtractText is false.
+	TrustText bool
+
+	// When NormalizeSelfLinks is true, self referencing links in the contents
+	// ("http://host", "https://host" and "//host") are rewritten into the same
+	// form before comparing.
+	NormalizeSelfLinks bool
 
-var client http.Client
+	// When Scrubber is non-nil, it's used to mask the volatile tokens in the
+	// contents before comparing.
+	Scrubber *Scrubber
+
+	// When Calibrate is true, Check fetches the http url twice,
+	// and uses the similarity between the two fetches as the baseline.
+	// The similarity between http and https urls is then normalized by the
+	// baseline before applying Threshold,
+	// so that dynamic pages that can't even match themselves are not wrongly
+	// rejected.
+	Calibrate bool
+
+	// When FullScores is true, all the scores are computed even when the
+	// contents are rejected,
+	// which is slower but tells how far they are from Threshold.
+	FullScores bool
+}
 
 // Check checks whether there's https url to http url urlStr with similar
 // content.
-func Check(ctx context.Context, urlStr string, peek int64, headers http.Header) (httpsURL string, sim float64, err error) {
+//
+// It's a thin wrapper around Checker.Check with a default client.
+func Check(ctx context.Context, urlStr string, peek int64, headers http.Header, opts Options) (*Result, error) {
+	c := Checker{
+		Headers:   headers,
+		ReadLimit: peek,
+		Options:   opts,
+	}
+	return c.Check(ctx, urlStr)
+}
+
+// Check checks whether there's https url to http url urlStr with similar
+// content.
+//
+// The returned Result is never nil,
+// and contains everything learned before the error when err is non-nil.
+func (c *Checker) Check(ctx context.Context, urlStr string) (*Result, error) {
+	result := &Result{
+		URL: urlStr,
+	}
 	u, err := url.Parse(urlStr)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to parse url %q: %w", urlStr, err)
+		return result, fmt.Errorf("failed to parse url %q: %w", urlStr, err)
 	}
 	if u.Scheme != "http" {
-		return "", 0, ErrNotHTTP
+		return result, ErrNotHTTP
+	}
+	if c.Cache != nil {
+		if cached, err, ok := c.Cache.lookup(u); ok {
+			return cached, err
+		}
+	}
+	result, httpsFailed, err := c.check(withSlotShare(ctx), u, result)
+	if errors.Is(err, ErrDisallowedByRobots) {
+		result.Outcome = OutcomeSkippedByRobots
+	}
+	// Errors caused by ctx or the rate limits say nothing about the url.
+	if c.Cache != nil && ctx.Err() == nil && !errors.Is(err, ErrRateLimited) {
+		c.Cache.store(u, result, err, httpsFailed)
+	}
+	return result, err
+}
+
+// check does the actual check of Check on the parsed http url u.
+//
+// httpsFailed reports whether err came from the https side,
+// instead of from the http url itself.
+func (c *Checker) check(ctx context.Context, u *url.URL, result *Result) (_ *Result, httpsFailed bool, err error) {
+	start := time.Now()
+	defer func() {
+		result.Took = time.Since(start)
+	}()
+
+	variants := hostVariants(u, c.HostVariants)
+	// The https url on the original host is fetched concurrently with the http
+	// url, the variants are only fetched after both of them.
+	both := c.fetchBoth(ctx, u, variants[0].url, len(variants) == 1)
+	result.HTTP = both.http
+	result.Baseline = both.baseline
+	if both.httpErr != nil && !both.httpAborted {
+		result.HTTPS = both.https
+		return result, false, both.httpErr
+	}
+
+	// When all the variants failed, the result and error of the original host
+	// are returned.
+	var (
+		firstResult *Result
+		firstErr    error
+	)
+	for i, v := range variants {
+		attempt := *result
+		attempt.Variant = v.variant
+		f, resp, err := both.https, both.httpsResp, both.httpsErr
+		if i > 0 {
+			f, resp, err = c.fetchHTTPS(ctx, v.url)
+		}
+		attempt.HTTPS = f
+		if err == nil {
+			err = c.checkHTTPS(ctx, &attempt, resp, u, both.oldContent, both.baseContent)
+		}
+		if err == nil {
+			*result = attempt
+			return result, false, nil
+		}
+		if i == 0 {
+			firstResult, firstErr = &attempt, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	*result = *firstResult
+	return result, true, firstErr
+}
+
+// fetched is the results of fetchBoth.
+type fetched struct {
+	http, baseline          *Fetch
+	oldContent, baseContent []byte
+	httpErr                 error
+
+	// Whether the http side was aborted because the https side failed.
+	httpAborted bool
+
+	https     *Fetch
+	httpsResp *response
+	httpsErr  error
+}
+
+// fetchBoth fetches the http url u (twice when Calibrate is true) and the
+// https candidates of httpU concurrently.
+//
+// When the http side fails, the https side is aborted.
+// When the https side fails and abortHTTP is true, the http side is aborted,
+// in which case httpAborted is true and httpErr is the error of aborting.
+func (c *Checker) fetchBoth(ctx context.Context, u, httpU *url.URL, abortHTTP bool) *fetched {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var f fetched
+	httpDone := make(chan struct{})
+	go func() {
+		defer close(httpDone)
+		f.http, f.oldContent, f.httpErr = c.fetch(ctx, u)
+		if f.httpErr == nil && c.Calibrate {
+			f.baseline, f.baseContent, f.httpErr = c.fetch(ctx, u)
+		}
+		if f.httpErr != nil {
+			cancel()
+		}
+	}()
+
+	f.https, f.httpsResp, f.httpsErr = c.fetchHTTPS(ctx, httpU)
+	if f.httpsErr != nil && abortHTTP {
+		cancel()
 	}
+	<-httpDone
+	f.httpAborted = f.httpsErr != nil && abortHTTP && errors.Is(f.httpErr, context.Canceled)
+	return &f
+}
+
+// checkHTTPS checks the fetched https side against the contents of the http
+// url original.
+//
+// The https side of result is filled with everything learned,
+// even when err is non-nil.
+func (c *Checker) checkHTTPS(
+	ctx context.Context,
+	result *Result,
+	httpsResp *response,
+	original *url.URL,
+	oldContent, baseContent []byte,
+) error {
+	httpsURL := result.HTTPS.URL
+	newContent := httpsResp.content
+	result.TLS = newTLS(httpsResp.tls)
+	result.HSTS = c.hsts(original, result.HTTPS, httpsResp.header)
 
-	oldContent, err := peekResponse(reqFromURL(ctx, u, headers), urlStr, peek)
+	outcome, httpsFinal, err := redirectOutcome(result.HTTP, result.HTTPS)
 	if err != nil {
-		return "", 0, err
+		result.Outcome = outcome
+		return err
+	}
+	if err := c.TLSPolicy.check(result.TLS, time.Now()); err != nil {
+		return fmt.Errorf("refused to recommend %q: %w", httpsFinal, err)
+	}
+	oldType := mediaType(result.HTTP.ContentType, oldContent)
+	newType := mediaType(result.HTTPS.ContentType, newContent)
+	if oldType != newType {
+		return fmt.Errorf(
+			"%q is %q while %q is %q: %w",
+			result.HTTP.FinalURL(),
+			oldType,
+			httpsFinal,
+			newType,
+			ErrContentTypeMismatch,
+		)
+	}
+	kind := kindOf(oldType)
+	if kind == ContentHTML {
+		mc := scanMixedContent(newContent, httpsFinal)
+		result.MixedContent = &mc
+		if c.BlockActiveMixedContent && mc.Active > 0 {
+			return fmt.Errorf(
+				"refused to recommend %q with %d active mixed content: %w",
+				httpsFinal,
+				mc.Active,
+				ErrMixedContent,
+			)
+		}
 	}
 
-	u.Scheme = "https"
-	httpsURL = u.String()
-	newContent, err := peekResponse(reqFromURL(ctx, u, headers), httpsURL, peek)
+	result.Scores, err = compareKind(
+		ctx,
+		kind,
+		result.HTTP,
+		result.HTTPS,
+		oldContent,
+		newContent,
+		baseContent,
+		original.Hostname(),
+		c.readLimit(),
+		c.Options,
+	)
+	if errors.Is(err, ErrTooDissimilar) {
+		return fmt.Errorf(
+			"https url %q has less than %v similarity: %w",
+			httpsURL,
+			c.Threshold,
+			err,
+		)
+	}
 	if err != nil {
-		return "", 0, err
+		return fmt.Errorf("failed to compare %q and %q: %w", result.URL, httpsURL, categorize(err))
+	}
+	result.HTTPSURL = c.recommend(ctx, result, kind, httpsFinal, original, oldContent, baseContent)
+	result.Outcome = outcome
+	return nil
+}
+
+// recommend returns the https url to recommend,
+// after the final https url is confirmed to have similar content.
+//
+// When the final https url has tracking parameters,
+// it fetches the url without them and only recommends that when it still
+// ends up on the same site over https with similar content,
+// as some sites need some of those parameters to serve the same resource.
+func (c *Checker) recommend(
+	ctx context.Context,
+	result *Result,
+	kind ContentKind,
+	httpsFinal, original *url.URL,
+	oldContent, baseContent []byte,
+) string {
+	canonical, stripped := canonicalURL(httpsFinal)
+	if !stripped {
+		return withFragment(canonical, original).String()
 	}
+	fallback := withFragment(dropDefaultPort(httpsFinal), original).String()
 
-	sim = similarity.MinSimilarity(oldContent, newContent)
-	return
+	var content []byte
+	var err error
+	result.Canonical, content, err = c.fetch(ctx, canonical)
+	if err != nil {
+		return fallback
+	}
+	if _, _, err := redirectOutcome(result.HTTP, result.Canonical); err != nil {
+		return fallback
+	}
+	if _, err := compareKind(
+		ctx,
+		kind,
+		result.HTTP,
+		result.Canonical,
+		oldContent,
+		content,
+		baseContent,
+		original.Hostname(),
+		c.readLimit(),
+		c.Options,
+	); err != nil {
+		return fallback
+	}
+	return withFragment(canonical, original).String()
 }
 
-func reqFromURL(ctx context.Context, u *url.URL, headers http.Header) *http.Request {
+// hsts returns the HSTS status from the http url u and the fetch of the https
+// url.
+func (c *Checker) hsts(u *url.URL, f *Fetch, header http.Header) *HSTS {
+	var hsts HSTS
+	// The header must be ignored when it's not from https, see RFC 6797 8.1.
+	if strings.HasPrefix(f.FinalURL(), "https://") {
+		// Invalid headers are recorded as-is without the directives.
+		hsts, _ = ParseHSTS(header.Get("strict-transport-security"))
+	}
+	if c.PreloadList != nil {
+		hsts.Preloaded = c.PreloadList.Contains(u.Hostname())
+	}
+	return &hsts
+}
+
+func (c *Checker) client() *http.Client {
+	client := &defaultClient
+	if c.Client != nil {
+		client = c.Client
+	}
+	if c.Limiter == nil && c.Robots == nil {
+		return client
+	}
+	wrapped := *client
+	if c.Limiter != nil {
+		wrapped.Transport = &limitedTransport{
+			base:    wrapped.Transport,
+			limiter: c.Limiter,
+		}
+	}
+	if c.Robots != nil {
+		// Outside of the limiter, so that fetching robots.txt is also limited.
+		wrapped.Transport = &robotsTransport{
+			base:      wrapped.Transport,
+			robots:    c.Robots,
+			userAgent: c.UserAgent,
+		}
+	}
+	return &wrapped
+}
+
+func (c *Checker) readLimit() int64 {
+	if c.ReadLimit > 0 {
+		return c.ReadLimit
+	}
+	return DefaultReadLimit
+}
+
+func (c *Checker) newRequest(ctx context.Context, u *url.URL) *http.Request {
+	headers := c.Headers.Clone()
+	if c.UserAgent != "" {
+		if headers == nil {
+			headers = make(http.Header)
+		}
+		headers.Set("user-agent", c.UserAgent)
+	}
 	req := http.Request{
 		Method: http.MethodGet,
 		URL:    u,
@@ -56,18 +470,73 @@ func reqFromURL(ctx context.Context, u *url.URL, headers http.Header) *http.Requ
 	return req.WithContext(ctx)
 }
 
-func peekResponse(req *http.Request, url string, peek int64) ([]byte, error) {
-	resp, err := client.Do(req)
+// response is the parts of the final response Fetch doesn't keep.
+type response struct {
+	content []byte
+	header  http.Header
+	tls     *tls.ConnectionState
+}
+
+// fetch fetches u and reads up to the read limit from the response body.
+//
+// The returned Fetch is never nil.
+func (c *Checker) fetch(ctx context.Context, u *url.URL) (*Fetch, []byte, error) {
+	f, resp, err := c.fetchResponse(ctx, u)
+	if resp == nil {
+		return f, nil, err
+	}
+	return f, resp.content, err
+}
+
+// fetchResponse is the version of fetch that also returns the response
+// headers and TLS connection state.
+//
+// The returned Fetch is never nil,
+// the returned response is nil when no response was received.
+func (c *Checker) fetchResponse(ctx context.Context, u *url.URL) (*Fetch, *response, error) {
+	urlStr := u.String()
+	f := &Fetch{
+		URL:           urlStr,
+		ContentLength: -1,
+	}
+	start := time.Now()
+	defer func() {
+		f.Took = time.Since(start)
+	}()
+
+	resp, err := c.client().Do(c.newRequest(ctx, u))
 	if err != nil {
-		return nil, fmt.Errorf("http request failed on %q: %w", url, err)
+		return f, nil, fmt.Errorf("http request failed on %q: %w", urlStr, categorize(err))
 	}
 	defer httpbp.DrainAndClose(resp.Body)
-	if err := httpbp.ClientErrorFromResponse(resp); err != nil {
-		return nil, fmt.Errorf("http request failed on %q: %w", url, err)
+	f.TimeToHeaders = time.Since(start)
+	f.Redirects = redirects(resp)
+	f.StatusCode = resp.StatusCode
+	f.ContentType = resp.Header.Get("content-type")
+	f.ContentLength = resp.ContentLength
+	r := &response{
+		header: resp.Header,
+		tls:    resp.TLS,
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return f, r, fmt.Errorf("http request failed on %q: %w", urlStr, &StatusError{
+			URL:        f.FinalURL(),
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+		})
+	}
+	body, encoding, err := decompress(resp)
+	if err != nil {
+		return f, r, fmt.Errorf("failed to read response for %q: %w", urlStr, err)
 	}
-	content, err := io.ReadAll(io.LimitReader(resp.Body, peek))
+	f.ContentEncoding = encoding
+	// The read limit applies to the decoded content,
+	// so that both sides are compared on the same amount of text.
+	body, f.Charset = decodeCharset(body, f.ContentType)
+	r.content, err = io.ReadAll(io.LimitReader(body, c.readLimit()))
+	f.BytesRead = len(r.content)
 	if err != nil {
-		err = fmt.Errorf("failed to read response for %q: %w", url, err)
+		err = fmt.Errorf("failed to read response for %q: %w", urlStr, categorize(err))
 	}
-	return content, err
+	return f, r, err
 }
@@ -0,0 +1,155 @@
+package check
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testHost is the host used in the urls checked by the checkers created by
+// newTestChecker.
+//
+// It's one of the hosts the certificate of httptest.NewTLSServer is valid for.
+const testHost = "example.com"
+
+// newTestChecker creates a Checker with its client connecting to the given
+// handlers.
+//
+// Connections to port 80 and 8080 of any host go to httpHandler,
+// and connections to port 443 and 8443 of any host go to httpsHandler over TLS.
+func newTestChecker(t *testing.T, httpHandler, httpsHandler http.Handler) *Checker {
+	t.Helper()
+	return newTestCheckerTLS(t, httpHandler, httpsHandler, nil)
+}
+
+// newTestCheckerTLS is newTestChecker with the https server using tlsConfig.
+//
+// When tlsConfig is nil, the default certificate of httptest.NewTLSServer is
+// used.
+// Otherwise the client trusts the first certificate of tlsConfig as a root,
+// callers can replace the RootCAs of the client's transport to change that.
+func newTestCheckerTLS(t *testing.T, httpHandler, httpsHandler http.Handler, tlsConfig *tls.Config) *Checker {
+	t.Helper()
+
+	httpServer := httptest.NewServer(httpHandler)
+	t.Cleanup(httpServer.Close)
+	httpsServer := httptest.NewUnstartedServer(httpsHandler)
+	httpsServer.TLS = tlsConfig
+	httpsServer.StartTLS()
+	t.Cleanup(httpsServer.Close)
+
+	transport := httpsServer.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		d := net.Dialer{
+			Control: testGuard.Control,
+		}
+		switch port {
+		case "80", "8080":
+			return d.DialContext(ctx, network, httpServer.Listener.Addr().String())
+		case "443", "8443":
+			return d.DialContext(ctx, network, httpsServer.Listener.Addr().String())
+		}
+		return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("unknown port " + port)}
+	}
+	return &Checker{
+		Client: &http.Client{
+			Transport: transport,
+		},
+	}
+}
+
+// testGuard is the AddressGuard allowing the loopback test servers.
+var testGuard = &AddressGuard{
+	Allow: mustParseCIDRs("127.0.0.0/8", "::1/128"),
+}
+
+// testClient is the client for the tests fetching the loopback test servers
+// directly.
+var testClient = &http.Client{
+	Transport: guardedTransport(testGuard),
+}
+
+func staticHandler(content string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html; charset=utf-8")
+		w.Write([]byte(content))
+	})
+}
+
+func TestCheckerCheck(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+
+	t.Run("similar", func(t *testing.T) {
+		c := newTestChecker(t, staticHandler(content), staticHandler(content))
+		c.Threshold = 0.95
+		result, err := c.Check(context.Background(), "http://"+testHost+"/foo")
+		if err != nil {
+			t.Fatalf("Check returned error: %v", err)
+		}
+		if result.HTTPSURL != "https://"+testHost+"/foo" {
+			t.Errorf("Unexpected https url %q", result.HTTPSURL)
+		}
+		if result.Scores.Similarity != 1 {
+			t.Errorf("Expected similarity 1, got %+v", result.Scores)
+		}
+		if result.HTTP == nil || result.HTTP.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected http fetch: %+v", result.HTTP)
+		}
+		if result.HTTPS == nil || result.HTTPS.StatusCode != http.StatusOK {
+			t.Errorf("Unexpected https fetch: %+v", result.HTTPS)
+		}
+	})
+
+	t.Run("dissimilar", func(t *testing.T) {
+		c := newTestChecker(t, staticHandler(content), staticHandler("<p>Something else</p>"))
+		c.Threshold = 0.95
+		result, err := c.Check(context.Background(), "http://"+testHost+"/foo")
+		if !errors.Is(err, ErrTooDissimilar) {
+			t.Errorf("Expected %v, got %v", ErrTooDissimilar, err)
+		}
+		if result.HTTPSURL != "" {
+			t.Errorf("Expected no https url, got %q", result.HTTPSURL)
+		}
+	})
+
+	t.Run("not-http", func(t *testing.T) {
+		c := newTestChecker(t, staticHandler(content), staticHandler(content))
+		_, err := c.Check(context.Background(), "https://"+testHost+"/foo")
+		if !errors.Is(err, ErrNotHTTP) {
+			t.Errorf("Expected %v, got %v", ErrNotHTTP, err)
+		}
+	})
+
+	t.Run("headers", func(t *testing.T) {
+		const (
+			ua  = "test-agent/1.0"
+			foo = "bar"
+		)
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("user-agent"); got != ua {
+				t.Errorf("Expected user-agent %q, got %q", ua, got)
+			}
+			if got := r.Header.Get("x-foo"); got != foo {
+				t.Errorf("Expected x-foo %q, got %q", foo, got)
+			}
+			w.Write([]byte(content))
+		})
+		c := newTestChecker(t, handler, handler)
+		c.UserAgent = ua
+		c.Headers = http.Header{
+			"User-Agent": {"overridden"},
+			"X-Foo":      {foo},
+		}
+		if _, err := c.Check(context.Background(), "http://"+testHost+"/"); err != nil {
+			t.Errorf("Check returned error: %v", err)
+		}
+	})
+}
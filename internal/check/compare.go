@@ -0,0 +1,137 @@
+package check
+
+import (
+	"context"
+)
+
+// Scores are the similarity scores between the contents of http and https
+// urls.
+type Scores struct {
+	// The similarity between the raw HTML contents.
+	HTML float64 `json:"html"`
+
+	// The similarity between the document titles and visible texts.
+	//
+	// It's only computed when Options.ExtractText is true.
+	Text float64 `json:"text,omitempty"`
+
+	// The similarity between two fetches of the http url,
+	// using the same score as the one Options.Threshold is applied to.
+	//
+	// It's only computed when Options.Calibrate is true.
+	Baseline float64 `json:"baseline,omitempty"`
+
+	// The similarity between the trusted parts of the contents,
+	// either HTML or Text, depending on Options.TrustText.
+	Raw float64 `json:"raw"`
+
+	// The score Options.Threshold is applied to.
+	//
+	// It's the same as Raw, unless Options.Calibrate is true,
+	// in which case it's Raw normalized by Baseline (capped at 1).
+	// Use Raw to tell how similar the contents are.
+	Similarity float64 `json:"similarity"`
+
+	// The kind of the contents, which decides how they are compared.
+	Kind ContentKind `json:"kind,omitempty"`
+
+	// Whether the comparison stopped as soon as the contents were rejected,
+	// leaving HTML, Text, Raw and Similarity not computed.
+	//
+	// It's never true when Options.FullScores is true,
+	// unless the baseline is 0.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// document is a content prepared for comparing.
+type document struct {
+	html []byte
+	text []byte
+}
+
+func prepare(content []byte, host string, opts Options) document {
+	if opts.NormalizeSelfLinks {
+		content = normalizeSelfLinks(content, host)
+	}
+	if opts.Scrubber != nil {
+		content = opts.Scrubber.Scrub(content)
+	}
+	d := document{html: content}
+	if opts.ExtractText {
+		d.text = extractText(content)
+	}
+	return d
+}
+
+// trusted returns the part of the document Options.Threshold is applied to.
+func (d document) trusted(opts Options) []byte {
+	if opts.ExtractText && opts.TrustText {
+		return d.text
+	}
+	return d.html
+}
+
+// compare computes the scores between the contents of http and https urls to
+// host.
+//
+// baseContent is the content of the second fetch of the http url,
+// it's only used when opts.Calibrate is true.
+//
+// It returns ErrTooDissimilar as soon as the trusted score is provably below
+// opts.Threshold, in which case the returned scores are partial,
+// unless opts.FullScores is true.
+func compare(ctx context.Context, oldContent, newContent, baseContent []byte, host string, opts Options) (scores Scores, err error) {
+	oldDoc := prepare(oldContent, host, opts)
+	newDoc := prepare(newContent, host, opts)
+
+	threshold := opts.Threshold
+	if opts.Calibrate {
+		baseDoc := prepare(baseContent, host, opts)
+		scores.Baseline, err = opts.Comparator.MinSimilarity(ctx, oldDoc.trusted(opts), baseDoc.trusted(opts))
+		if err != nil {
+			return scores, err
+		}
+		if scores.Baseline == 0 {
+			// The page has nothing in common with itself,
+			// there's no way to tell whether the https version is the same page.
+			scores.Partial = true
+			return scores, ErrTooDissimilar
+		}
+		threshold *= scores.Baseline
+	}
+
+	if !opts.FullScores {
+		ok, err := opts.Comparator.AtLeast(ctx, oldDoc.trusted(opts), newDoc.trusted(opts), threshold)
+		if err != nil {
+			return scores, err
+		}
+		if !ok {
+			scores.Partial = true
+			return scores, ErrTooDissimilar
+		}
+	}
+
+	if scores.HTML, err = opts.Comparator.MinSimilarity(ctx, oldDoc.html, newDoc.html); err != nil {
+		return scores, err
+	}
+	if opts.ExtractText {
+		if scores.Text, err = opts.Comparator.MinSimilarity(ctx, oldDoc.text, newDoc.text); err != nil {
+			return scores, err
+		}
+	}
+	scores.Raw = scores.HTML
+	if opts.ExtractText && opts.TrustText {
+		scores.Raw = scores.Text
+	}
+	scores.Similarity = scores.Raw
+	if opts.Calibrate {
+		scores.Similarity /= scores.Baseline
+		if scores.Similarity > 1 {
+			scores.Similarity = 1
+		}
+	}
+	if opts.FullScores && scores.Raw < threshold {
+		return scores, ErrTooDissimilar
+	}
+	return scores, nil
+}
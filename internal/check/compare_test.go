@@ -0,0 +1,75 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCompareCalibrate(t *testing.T) {
+	// A page with a lot of dynamic content that only has 90% similarity with
+	// itself.
+	oldContent := []byte(`<p>Static content!</p><p>0123456789</p><p>Static content!</p>`)
+	baseContent := []byte(`<p>Static content!</p><p>abcdefghij</p><p>Static content!</p>`)
+	newContent := []byte(`<p>Static content!</p><p>ABCDEFGHIJ</p><p>Static content!</p>`)
+
+	t.Run("absolute", func(t *testing.T) {
+		_, err := compare(context.Background(), oldContent, newContent, baseContent, "", Options{
+			Threshold: 0.95,
+		})
+		if !errors.Is(err, ErrTooDissimilar) {
+			t.Errorf("Expected %v, got %v", ErrTooDissimilar, err)
+		}
+	})
+
+	t.Run("calibrated", func(t *testing.T) {
+		scores, err := compare(context.Background(), oldContent, newContent, baseContent, "", Options{
+			Threshold: 0.95,
+			Calibrate: true,
+		})
+		if err != nil {
+			t.Fatalf("compare returned error: %v", err)
+		}
+		if scores.Baseline != scores.HTML {
+			t.Errorf("Expected baseline %v to be the same as html score %v", scores.Baseline, scores.HTML)
+		}
+		if scores.Similarity != 1 {
+			t.Errorf("Expected normalized similarity to be 1, got %+v", scores)
+		}
+		if scores.Raw != scores.HTML {
+			t.Errorf("Expected raw similarity %v to be the html score, got %+v", scores.HTML, scores)
+		}
+	})
+
+	t.Run("calibrated-dissimilar", func(t *testing.T) {
+		newContent := []byte(`<p>Totally different content!</p>`)
+		scores, err := compare(context.Background(), oldContent, newContent, baseContent, "", Options{
+			Threshold: 0.95,
+			Calibrate: true,
+		})
+		if !errors.Is(err, ErrTooDissimilar) {
+			t.Errorf("Expected %v, got %v, scores: %+v", ErrTooDissimilar, err, scores)
+		}
+		if !scores.Partial {
+			t.Errorf("Expected partial scores, got %+v", scores)
+		}
+	})
+
+	t.Run("calibrated-dissimilar-full", func(t *testing.T) {
+		newContent := []byte(`<p>Totally different content!</p>`)
+		scores, err := compare(context.Background(), oldContent, newContent, baseContent, "", Options{
+			Threshold:  0.95,
+			Calibrate:  true,
+			FullScores: true,
+		})
+		if !errors.Is(err, ErrTooDissimilar) {
+			t.Errorf("Expected %v, got %v, scores: %+v", ErrTooDissimilar, err, scores)
+		}
+		if scores.Partial || scores.Raw == 0 || scores.Raw != scores.HTML {
+			t.Errorf("Expected full scores, got %+v", scores)
+		}
+		if expected := scores.Raw / scores.Baseline; scores.Similarity != expected {
+			t.Errorf("Expected normalized similarity %v, got %+v", expected, scores)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowHandler delays handler by latency,
+// or until the client gives up on the request.
+func slowHandler(latency time.Duration, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(latency):
+			handler.ServeHTTP(w, r)
+		case <-r.Context().Done():
+		}
+	})
+}
+
+// waitingHandler calls arrive and then serves handler once ready is closed.
+//
+// When ready is not closed before timeout, it fails the request instead,
+// so that the check proves the requests ready waits for are in flight at the
+// same time, without measuring the time taken.
+func waitingHandler(arrive func(), ready <-chan struct{}, timeout time.Duration, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrive()
+		select {
+		case <-ready:
+			handler.ServeHTTP(w, r)
+		case <-time.After(timeout):
+			http.Error(w, "not concurrent", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+func TestCheckConcurrent(t *testing.T) {
+	const (
+		content = "<p>Hello, world!</p>"
+		latency = 200 * time.Millisecond
+		// Long enough to fail the tests if the fast failing side doesn't abort
+		// the other side.
+		stuck = 10 * time.Second
+	)
+
+	t.Run("parallel", func(t *testing.T) {
+		// Both sides are only served after both of them arrived.
+		var arrived sync.WaitGroup
+		arrived.Add(2)
+		ready := make(chan struct{})
+		go func() {
+			arrived.Wait()
+			close(ready)
+		}()
+		checker := newTestChecker(
+			t,
+			waitingHandler(arrived.Done, ready, stuck, staticHandler(content)),
+			waitingHandler(arrived.Done, ready, stuck, staticHandler(content)),
+		)
+		if _, err := checker.Check(context.Background(), "http://"+testHost+"/"); err != nil {
+			t.Fatalf("Expected both sides to be fetched concurrently, got %v", err)
+		}
+	})
+
+	t.Run("calibrate", func(t *testing.T) {
+		// The http fetches are only served after the https side arrived,
+		// and the https side only after the second http fetch arrived.
+		var (
+			httpArrivals int32
+			httpsArrived = make(chan struct{})
+			secondHTTP   = make(chan struct{})
+		)
+		checker := newTestChecker(
+			t,
+			waitingHandler(func() {
+				if atomic.AddInt32(&httpArrivals, 1) == 2 {
+					close(secondHTTP)
+				}
+			}, httpsArrived, stuck, staticHandler(content)),
+			waitingHandler(func() {
+				close(httpsArrived)
+			}, secondHTTP, stuck, staticHandler(content)),
+		)
+		checker.Calibrate = true
+		if _, err := checker.Check(context.Background(), "http://"+testHost+"/"); err != nil {
+			t.Fatalf("Expected the https side to overlap both http fetches, got %v", err)
+		}
+	})
+
+	t.Run("http-fails", func(t *testing.T) {
+		checker := newTestChecker(
+			t,
+			http.NotFoundHandler(),
+			slowHandler(stuck, staticHandler(content)),
+		)
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		if !errors.Is(err, ErrStatus) {
+			t.Errorf("Expected %v, got %v", ErrStatus, err)
+		}
+		if !strings.HasPrefix(err.Error(), `http request failed on "http://`) {
+			t.Errorf("Expected the error from the http side, got %v", err)
+		}
+		if result.Took >= stuck/2 {
+			t.Errorf("Expected the https side to be aborted, took %v", result.Took)
+		}
+	})
+
+	t.Run("https-fails", func(t *testing.T) {
+		checker := newTestChecker(
+			t,
+			slowHandler(stuck, staticHandler(content)),
+			http.NotFoundHandler(),
+		)
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		if !errors.Is(err, ErrStatus) {
+			t.Errorf("Expected %v, got %v", ErrStatus, err)
+		}
+		if !strings.HasPrefix(err.Error(), `http request failed on "https://`) {
+			t.Errorf("Expected the error from the https side, got %v", err)
+		}
+		if result.Took >= stuck/2 {
+			t.Errorf("Expected the http side to be aborted, took %v", result.Took)
+		}
+	})
+
+	t.Run("https-fails-with-variants", func(t *testing.T) {
+		checker := newTestChecker(
+			t,
+			slowHandler(latency, staticHandler(content)),
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Host != "www."+testHost {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(content))
+			}),
+		)
+		checker.HostVariants = []HostVariant{HostVariantWWW}
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		if err != nil {
+			t.Fatalf("Expected the http side to be kept for the variants, got %v", err)
+		}
+		if result.Variant != HostVariantWWW {
+			t.Errorf("Expected variant %q, got %q", HostVariantWWW, result.Variant)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ContentKind is the kind of the contents,
+// which decides how they are compared.
+type ContentKind string
+
+// Content kinds.
+const (
+	// HTML and other text contents,
+	// compared with Options.Comparator.
+	ContentHTML ContentKind = "html"
+
+	// PDF documents,
+	// compared on their texts with Options.Comparator.
+	ContentPDF ContentKind = "pdf"
+
+	// Everything else,
+	// which must have the same size and the same bytes up to the read limit.
+	ContentBinary ContentKind = "binary"
+)
+
+// mediaType returns the media type of the content,
+// from the content type header or sniffed from the content when the header
+// is missing or invalid.
+func mediaType(contentType string, content []byte) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(content))
+	return mt
+}
+
+func kindOf(mediaType string) ContentKind {
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/xhtml+xml",
+		mediaType == "application/xml",
+		mediaType == "application/json",
+		mediaType == "application/javascript":
+		return ContentHTML
+	case mediaType == "application/pdf":
+		return ContentPDF
+	}
+	return ContentBinary
+}
+
+// compareKind dispatches the comparison of the contents by their kind.
+//
+// oldFetch and newFetch are only used for their content lengths,
+// and readLimit caps the texts extracted from the PDF documents.
+func compareKind(
+	ctx context.Context,
+	kind ContentKind,
+	oldFetch, newFetch *Fetch,
+	oldContent, newContent, baseContent []byte,
+	host string,
+	readLimit int64,
+	opts Options,
+) (scores Scores, err error) {
+	switch kind {
+	default:
+		scores, err = compare(ctx, oldContent, newContent, baseContent, host, opts)
+	case ContentPDF:
+		oldText := extractPDFText(oldContent, readLimit)
+		newText := extractPDFText(newContent, readLimit)
+		if len(oldText) == 0 && len(newText) == 0 {
+			// No text to compare, e.g. scanned documents.
+			scores, err = compareBinary(oldFetch, newFetch, oldContent, newContent)
+			break
+		}
+		// The options for html don't apply to the extracted texts.
+		textOpts := Options{
+			Threshold:  opts.Threshold,
+			Comparator: opts.Comparator,
+			Calibrate:  opts.Calibrate,
+			FullScores: opts.FullScores,
+		}
+		scores, err = compare(ctx, oldText, newText, extractPDFText(baseContent, readLimit), host, textOpts)
+	case ContentBinary:
+		scores, err = compareBinary(oldFetch, newFetch, oldContent, newContent)
+	}
+	scores.Kind = kind
+	return scores, err
+}
+
+// compareBinary compares binary contents by their sizes and bytes read.
+//
+// As only the contents up to the read limit are read,
+// it only proves the equality of the whole contents when they are fully read.
+func compareBinary(oldFetch, newFetch *Fetch, oldContent, newContent []byte) (Scores, error) {
+	if oldFetch.ContentLength >= 0 && newFetch.ContentLength >= 0 &&
+		oldFetch.ContentLength != newFetch.ContentLength {
+		return Scores{}, ErrTooDissimilar
+	}
+	if !bytes.Equal(oldContent, newContent) {
+		return Scores{}, ErrTooDissimilar
+	}
+	return Scores{Raw: 1, Similarity: 1}, nil
+}
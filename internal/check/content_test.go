@@ -0,0 +1,124 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestKind(t *testing.T) {
+	for _, c := range []struct {
+		contentType string
+		content     string
+		mediaType   string
+		kind        ContentKind
+	}{
+		{
+			contentType: "text/html; charset=utf-8",
+			mediaType:   "text/html",
+			kind:        ContentHTML,
+		},
+		{
+			contentType: "Text/Plain",
+			mediaType:   "text/plain",
+			kind:        ContentHTML,
+		},
+		{
+			contentType: "application/pdf",
+			mediaType:   "application/pdf",
+			kind:        ContentPDF,
+		},
+		{
+			contentType: "application/gzip",
+			mediaType:   "application/gzip",
+			kind:        ContentBinary,
+		},
+		{
+			content:   "%PDF-1.4\n",
+			mediaType: "application/pdf",
+			kind:      ContentPDF,
+		},
+		{
+			content:   "<html><p>Hello, world!</p></html>",
+			mediaType: "text/html",
+			kind:      ContentHTML,
+		},
+	} {
+		t.Run(c.contentType+c.content, func(t *testing.T) {
+			mt := mediaType(c.contentType, []byte(c.content))
+			if mt != c.mediaType {
+				t.Errorf("Expected media type %q, got %q", c.mediaType, mt)
+			}
+			if kind := kindOf(mt); kind != c.kind {
+				t.Errorf("Expected kind %q, got %q", c.kind, kind)
+			}
+		})
+	}
+}
+
+func TestCheckContentKinds(t *testing.T) {
+	handler := func(contentType string, content []byte) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", contentType)
+			w.Write(content)
+		})
+	}
+	binary := []byte("\x1f\x8b\x08\x00binary content")
+
+	for _, c := range []struct {
+		label        string
+		httpHandler  http.Handler
+		httpsHandler http.Handler
+		kind         ContentKind
+		err          error
+	}{
+		{
+			label:        "pdf",
+			httpHandler:  handler("application/pdf", newTestPDF(false, "Hello, world!")),
+			httpsHandler: handler("application/pdf", newTestPDF(true, "Hello, world!")),
+			kind:         ContentPDF,
+		},
+		{
+			label:        "pdf-different",
+			httpHandler:  handler("application/pdf", newTestPDF(false, "Hello, world!")),
+			httpsHandler: handler("application/pdf", newTestPDF(false, "Something completely different.")),
+			kind:         ContentPDF,
+			err:          ErrTooDissimilar,
+		},
+		{
+			label:        "binary",
+			httpHandler:  handler("application/gzip", binary),
+			httpsHandler: handler("application/gzip", binary),
+			kind:         ContentBinary,
+		},
+		{
+			label:        "binary-different",
+			httpHandler:  handler("application/gzip", binary),
+			httpsHandler: handler("application/gzip", append(binary[:len(binary):len(binary)], '!')),
+			kind:         ContentBinary,
+			err:          ErrTooDissimilar,
+		},
+		{
+			label:        "mismatch",
+			httpHandler:  handler("application/pdf", newTestPDF(false, "Hello, world!")),
+			httpsHandler: handler("text/html", newTestPDF(false, "Hello, world!")),
+			err:          ErrContentTypeMismatch,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			checker := newTestChecker(t, c.httpHandler, c.httpsHandler)
+			checker.Threshold = 0.95
+			result, err := checker.Check(context.Background(), "http://"+testHost+"/file")
+			if !errors.Is(err, c.err) {
+				t.Errorf("Expected error %v, got %v", c.err, err)
+			}
+			if result.Scores.Kind != c.kind {
+				t.Errorf("Expected kind %q, got %q", c.kind, result.Scores.Kind)
+			}
+			if c.err == nil && result.Scores.Similarity != 1 {
+				t.Errorf("Expected similarity 1, got %v", result.Scores.Similarity)
+			}
+		})
+	}
+}
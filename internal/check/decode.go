@@ -0,0 +1,103 @@
+package check
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// metaSniffLen is the number of bytes looked into for the charset,
+// as the WHATWG encoding sniffing algorithm does.
+const metaSniffLen = 1024
+
+// decompress returns the reader of the decompressed body of resp,
+// and the content encoding undone.
+//
+// The http transport only decompresses the body transparently when it's the
+// one requesting the compression,
+// so this is only needed when an accept-encoding header is sent explicitly.
+func decompress(resp *http.Response) (io.Reader, string, error) {
+	if resp.Uncompressed {
+		return resp.Body, "", nil
+	}
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("content-encoding")))
+	switch encoding {
+	case "", "identity":
+		return resp.Body, "", nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(resp.Body)
+		return r, encoding, err
+	case "deflate":
+		// "deflate" is supposed to be zlib wrapped,
+		// but some servers send raw deflate instead.
+		br := bufio.NewReader(resp.Body)
+		header, err := br.Peek(2)
+		if err == nil && isZlibHeader(header) {
+			r, err := zlib.NewReader(br)
+			return r, encoding, err
+		}
+		return flate.NewReader(br), encoding, nil
+	case "br":
+		return brotli.NewReader(resp.Body), encoding, nil
+	}
+	return nil, encoding, fmt.Errorf("unsupported content-encoding %q", encoding)
+}
+
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
+
+// decodeCharset returns the reader of r decoded into utf-8,
+// and the name of the charset it's decoded from.
+//
+// The charset is detected with the WHATWG encoding sniffing algorithm:
+// from the byte order mark,
+// the charset parameter of contentType,
+// and the <meta> tags at the beginning of the content, in that order.
+// Contents of non-text media types are returned as-is.
+func decodeCharset(r io.Reader, contentType string) (io.Reader, string) {
+	br := bufio.NewReaderSize(r, metaSniffLen)
+	// Errors are reported by the following reads.
+	head, _ := br.Peek(metaSniffLen)
+	if kindOf(mediaType(contentType, head)) != ContentHTML {
+		return br, ""
+	}
+
+	e, name, _ := charset.DetermineEncoding(head, contentType)
+	// The decoders keep the byte order mark.
+	br.Discard(bomLen(head, name))
+	if e == encoding.Nop || e == unicode.UTF8 {
+		return br, name
+	}
+	return transform.NewReader(br, e.NewDecoder()), name
+}
+
+// bomLen returns the length of the byte order mark of charset name at the
+// beginning of head, or 0 if there's none.
+func bomLen(head []byte, name string) int {
+	var bom []byte
+	switch name {
+	case "utf-8":
+		bom = []byte{0xef, 0xbb, 0xbf}
+	case "utf-16le":
+		bom = []byte{0xff, 0xfe}
+	case "utf-16be":
+		bom = []byte{0xfe, 0xff}
+	}
+	if bom != nil && bytes.HasPrefix(head, bom) {
+		return len(bom)
+	}
+	return 0
+}
@@ -0,0 +1,250 @@
+package check
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestDecodeCharset(t *testing.T) {
+	for _, c := range []struct {
+		label       string
+		content     []byte
+		contentType string
+		expected    string
+		charset     string
+	}{
+		{
+			label:       "utf-8",
+			content:     []byte("<p>Café</p>"),
+			contentType: "text/html; charset=UTF-8",
+			expected:    "<p>Café</p>",
+			charset:     "utf-8",
+		},
+		{
+			label:       "latin1-header",
+			content:     []byte("<p>Caf\xe9 \x80</p>"),
+			contentType: "text/html; charset=ISO-8859-1",
+			expected:    "<p>Café €</p>",
+			charset:     "windows-1252",
+		},
+		{
+			label:       "latin1-meta",
+			content:     []byte(`<meta http-equiv="Content-Type" content="text/html; charset=latin1"><p>Caf` + "\xe9</p>"),
+			contentType: "text/html",
+			expected:    `<meta http-equiv="Content-Type" content="text/html; charset=latin1"><p>Café</p>`,
+			charset:     "windows-1252",
+		},
+		{
+			label:       "header-over-meta",
+			content:     []byte(`<meta charset="windows-1252"><p>Café</p>`),
+			contentType: "text/html; charset=utf-8",
+			expected:    `<meta charset="windows-1252"><p>Café</p>`,
+			charset:     "utf-8",
+		},
+		{
+			label:       "utf-8-bom",
+			content:     []byte("\xef\xbb\xbf<p>Café</p>"),
+			contentType: "text/html; charset=latin1",
+			expected:    "<p>Café</p>",
+			charset:     "utf-8",
+		},
+		{
+			label:    "utf-16le-bom",
+			content:  []byte("\xff\xfe<\x00p\x00>\x00=\xd8\x00\xde"),
+			expected: "<p>😀",
+			charset:  "utf-16le",
+		},
+		{
+			label:       "utf-16be",
+			content:     []byte("\x00C\x00a\x00f\x00\xe9\xd8"),
+			contentType: "text/plain; charset=utf-16be",
+			expected:    "Café�",
+			charset:     "utf-16be",
+		},
+		{
+			label:       "shift_jis",
+			content:     []byte("<p>\x82\xa0</p>"),
+			contentType: "text/html; charset=Shift_JIS",
+			expected:    "<p>あ</p>",
+			charset:     "shift_jis",
+		},
+		{
+			label:       "gbk",
+			content:     []byte("<p>\xc4\xe3\xba\xc3</p>"),
+			contentType: "text/html; charset=GBK",
+			expected:    "<p>你好</p>",
+			charset:     "gbk",
+		},
+		{
+			label:    "euc-kr-meta",
+			content:  []byte(`<meta charset="euc-kr"><p>` + "\xbe\xc8\xb3\xe7</p>"),
+			expected: `<meta charset="euc-kr"><p>안녕</p>`,
+			charset:  "euc-kr",
+		},
+		{
+			label:       "koi8-r",
+			content:     []byte("<p>\xf0\xd2\xc9\xd7\xc5\xd4</p>"),
+			contentType: "text/html; charset=koi8-r",
+			expected:    "<p>Привет</p>",
+			charset:     "koi8-r",
+		},
+		{
+			// WHATWG treats utf-16 in <meta> as utf-8,
+			// as the meta tag itself could only be read as ascii.
+			label:    "utf-16-meta",
+			content:  []byte(`<meta charset="utf-16"><p>Hello</p>`),
+			expected: `<meta charset="utf-16"><p>Hello</p>`,
+			charset:  "utf-8",
+		},
+		{
+			label:    "undeclared-utf-8",
+			content:  []byte("<p>Café</p>"),
+			expected: "<p>Café</p>",
+			charset:  "utf-8",
+		},
+		{
+			label:       "binary",
+			content:     []byte("\x89PNG\r\n\x1a\n\xe9"),
+			contentType: "image/png",
+			expected:    "\x89PNG\r\n\x1a\n\xe9",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			r, charset := decodeCharset(bytes.NewReader(c.content), c.contentType)
+			if charset != c.charset {
+				t.Errorf("Expected charset %q, got %q", c.charset, charset)
+			}
+			actual, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			if string(actual) != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+	compress := func(newWriter func(io.Writer) io.WriteCloser) []byte {
+		var buf bytes.Buffer
+		w := newWriter(&buf)
+		io.WriteString(w, content)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	for _, c := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{
+			encoding: "",
+			body:     []byte(content),
+		},
+		{
+			encoding: "gzip",
+			body: compress(func(w io.Writer) io.WriteCloser {
+				return gzip.NewWriter(w)
+			}),
+		},
+		{
+			encoding: "deflate",
+			body: compress(func(w io.Writer) io.WriteCloser {
+				return zlib.NewWriter(w)
+			}),
+		},
+		{
+			encoding: "deflate",
+			body: compress(func(w io.Writer) io.WriteCloser {
+				fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+				return fw
+			}),
+		},
+		{
+			encoding: "br",
+			body: compress(func(w io.Writer) io.WriteCloser {
+				return brotli.NewWriter(w)
+			}),
+		},
+	} {
+		t.Run(c.encoding, func(t *testing.T) {
+			resp := &http.Response{
+				Header: http.Header{"Content-Encoding": {c.encoding}},
+				Body:   io.NopCloser(bytes.NewReader(c.body)),
+			}
+			r, encoding, err := decompress(resp)
+			if err != nil {
+				t.Fatalf("decompress returned error: %v", err)
+			}
+			if encoding != c.encoding {
+				t.Errorf("Expected encoding %q, got %q", c.encoding, encoding)
+			}
+			actual, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("Failed to read: %v", err)
+			}
+			if string(actual) != content {
+				t.Errorf("Expected %q, got %q", content, actual)
+			}
+		})
+	}
+
+	t.Run("unsupported", func(t *testing.T) {
+		resp := &http.Response{
+			Header: http.Header{"Content-Encoding": {"zstd"}},
+			Body:   io.NopCloser(strings.NewReader(content)),
+		}
+		if _, _, err := decompress(resp); err == nil {
+			t.Error("Expected error on unsupported encoding")
+		}
+	})
+}
+
+func TestCheckDecoding(t *testing.T) {
+	const content = "<p>Café: " + "the quick brown fox jumps over the lazy dog. " + "</p>"
+	latin1 := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html; charset=iso-8859-1")
+		w.Write([]byte(strings.Replace(content, "é", "\xe9", 1)))
+	})
+	gzipped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html; charset=utf-8")
+		w.Header().Set("content-encoding", "gzip")
+		gw := gzip.NewWriter(w)
+		io.WriteString(gw, content)
+		gw.Close()
+	})
+
+	checker := newTestChecker(t, latin1, gzipped)
+	// Requesting gzip explicitly disables the transparent decompression of the
+	// http transport.
+	checker.Headers = http.Header{"Accept-Encoding": {"gzip"}}
+	checker.ReadLimit = 20
+	result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	if result.Scores.Similarity != 1 {
+		t.Errorf("Expected similarity 1, got %v", result.Scores.Similarity)
+	}
+	if result.HTTP.Charset != "windows-1252" || result.HTTPS.ContentEncoding != "gzip" {
+		t.Errorf("Unexpected decoding: %+v, %+v", result.HTTP, result.HTTPS)
+	}
+	if result.HTTP.BytesRead != 20 || result.HTTPS.BytesRead != 20 {
+		t.Errorf(
+			"Expected the read limit to apply to the decoded contents, got %d and %d",
+			result.HTTP.BytesRead,
+			result.HTTPS.BytesRead,
+		)
+	}
+}
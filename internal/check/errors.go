@@ -0,0 +1,164 @@
+package check
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Common errors
+var (
+	ErrNotHTTP       = errors.New("not an http url")
+	ErrTooDissimilar = errors.New("contents are not similar enough")
+	ErrDowngrades    = errors.New("https url redirects to http")
+	ErrHostMismatch  = errors.New("http and https urls end up on different hosts")
+	ErrTLSPolicy     = errors.New("https url violates the tls policy")
+	ErrMixedContent  = errors.New("https page loads active content over http")
+
+	ErrContentTypeMismatch = errors.New("http and https urls have different content types")
+	ErrDisallowedByRobots  = errors.New("disallowed by robots.txt")
+)
+
+// Categories of failures when fetching the urls.
+//
+// The errors returned by Check can be matched against them with errors.Is.
+var (
+	ErrDNS               = errors.New("dns lookup failed")
+	ErrBlockedAddress    = errors.New("address blocked by the guard")
+	ErrConnectionRefused = errors.New("connection refused")
+	ErrTLSHandshake      = errors.New("tls handshake failed")
+	ErrCertHostname      = errors.New("certificate hostname mismatch")
+	ErrCertExpired       = errors.New("certificate expired")
+	ErrStatus            = errors.New("non-2xx status")
+	ErrTimeout           = errors.New("timed out")
+	ErrRateLimited       = errors.New("host rate limit exhausted")
+)
+
+// categories are all the categories Category can return, in the order they
+// are checked.
+var categories = []error{
+	ErrNotHTTP,
+	ErrTooDissimilar,
+	ErrDowngrades,
+	ErrHostMismatch,
+	ErrTLSPolicy,
+	ErrMixedContent,
+	ErrContentTypeMismatch,
+	ErrDisallowedByRobots,
+	ErrDNS,
+	ErrBlockedAddress,
+	ErrConnectionRefused,
+	ErrCertHostname,
+	ErrCertExpired,
+	ErrTLSHandshake,
+	ErrStatus,
+	ErrTimeout,
+	ErrRateLimited,
+}
+
+// Category returns the category err belongs to,
+// which is one of the errors defined in this package,
+// or nil if it doesn't belong to any of them.
+//
+// It's useful to count the errors returned by Check by their categories.
+func Category(err error) error {
+	for _, c := range categories {
+		if errors.Is(err, c) {
+			return c
+		}
+	}
+	return nil
+}
+
+// StatusError is the error returned when either side responded with a non-2xx
+// status.
+//
+// It matches ErrStatus with errors.Is.
+type StatusError struct {
+	// The url the status is from,
+	// after following the redirects.
+	URL string
+
+	Status     string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%q responded with %q", e.URL, e.Status)
+}
+
+// Is implements the interface used by errors.Is.
+func (e *StatusError) Is(target error) bool {
+	return target == ErrStatus
+}
+
+// categorizedError is an error with its category.
+type categorizedError struct {
+	category error
+	err      error
+}
+
+func (e *categorizedError) Error() string {
+	return fmt.Sprintf("%v: %v", e.category, e.err)
+}
+
+func (e *categorizedError) Is(target error) bool {
+	return target == e.category
+}
+
+func (e *categorizedError) Unwrap() error {
+	return e.err
+}
+
+// categorize wraps err with its category,
+// so that it can be matched against the category with errors.Is.
+//
+// If err doesn't belong to any of the categories, it's returned as-is.
+func categorize(err error) error {
+	if err == nil || Category(err) != nil {
+		return err
+	}
+	if category := categoryOf(err); category != nil {
+		return &categorizedError{
+			category: category,
+			err:      err,
+		}
+	}
+	return err
+}
+
+func categoryOf(err error) error {
+	var (
+		dnsErr              *net.DNSError
+		hostnameErr         x509.HostnameError
+		invalidErr          x509.CertificateInvalidError
+		unknownAuthorityErr x509.UnknownAuthorityError
+		recordHeaderErr     tls.RecordHeaderError
+		netErr              net.Error
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return ErrDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrConnectionRefused
+	case errors.As(err, &hostnameErr):
+		return ErrCertHostname
+	case errors.As(err, &invalidErr) && invalidErr.Reason == x509.Expired:
+		return ErrCertExpired
+	case errors.As(err, &invalidErr),
+		errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &recordHeaderErr),
+		// TLS alerts are not exported in crypto/tls.
+		strings.Contains(err.Error(), "tls: "):
+		return ErrTLSHandshake
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrTimeout
+	}
+	return nil
+}
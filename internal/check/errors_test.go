@@ -0,0 +1,151 @@
+package check
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCategory(t *testing.T) {
+	for _, c := range []struct {
+		label    string
+		err      error
+		expected error
+	}{
+		{
+			label:    "nil",
+			err:      nil,
+			expected: nil,
+		},
+		{
+			label:    "unknown",
+			err:      errors.New("foo"),
+			expected: nil,
+		},
+		{
+			label:    "dns",
+			err:      &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true},
+			expected: ErrDNS,
+		},
+		{
+			label: "refused",
+			err: &net.OpError{
+				Op:  "dial",
+				Net: "tcp",
+				Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+			},
+			expected: ErrConnectionRefused,
+		},
+		{
+			label:    "hostname",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}},
+			expected: ErrCertHostname,
+		},
+		{
+			label:    "expired",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: x509.CertificateInvalidError{Reason: x509.Expired}},
+			expected: ErrCertExpired,
+		},
+		{
+			label:    "unknown-authority",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}},
+			expected: ErrTLSHandshake,
+		},
+		{
+			label:    "alert",
+			err:      &url.Error{Op: "Get", URL: "https://example.com", Err: errors.New("remote error: tls: handshake failure")},
+			expected: ErrTLSHandshake,
+		},
+		{
+			label:    "deadline",
+			err:      fmt.Errorf("foo: %w", context.DeadlineExceeded),
+			expected: ErrTimeout,
+		},
+		{
+			label:    "status",
+			err:      fmt.Errorf("foo: %w", &StatusError{StatusCode: http.StatusNotFound}),
+			expected: ErrStatus,
+		},
+		{
+			label:    "dissimilar",
+			err:      fmt.Errorf("foo: %w", ErrTooDissimilar),
+			expected: ErrTooDissimilar,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			err := categorize(c.err)
+			if actual := Category(err); actual != c.expected {
+				t.Errorf("Expected category %v, got %v", c.expected, actual)
+			}
+			if c.expected != nil && !errors.Is(err, c.expected) {
+				t.Errorf("Expected errors.Is(%v, %v) to be true", err, c.expected)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Errorf("Expected categorized error %v to wrap %v", err, c.err)
+			}
+		})
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	t.Run("refused", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := l.Addr().String()
+		l.Close()
+
+		_, _, err = (&Checker{Client: testClient}).fetch(context.Background(), &url.URL{Scheme: "http", Host: addr})
+		if !errors.Is(err, ErrConnectionRefused) {
+			t.Errorf("Expected %v, got %v", ErrConnectionRefused, err)
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+		u, _ := url.Parse(server.URL)
+
+		_, _, err := (&Checker{Client: testClient, ReadLimit: 1024}).fetch(context.Background(), u)
+		var se *StatusError
+		if !errors.As(err, &se) {
+			t.Fatalf("Expected *StatusError, got %v", err)
+		}
+		if se.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, se.StatusCode)
+		}
+		if !errors.Is(err, ErrStatus) {
+			t.Errorf("Expected %v, got %v", ErrStatus, err)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}))
+		defer server.Close()
+		u, _ := url.Parse(server.URL)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+		defer cancel()
+		_, _, err := (&Checker{Client: testClient}).fetch(ctx, u)
+		if !errors.Is(err, ErrTimeout) {
+			t.Errorf("Expected %v, got %v", ErrTimeout, err)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package check
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// extractText extracts the document title and the visible text from HTML
+// content.
+//
+// Contents of script, style, noscript and template elements,
+// and comments are dropped.
+// Whitespaces are normalized,
+// so that changes to indentations and line breaks don't make a difference.
+//
+// The returned content is the title in the first line,
+// followed by the visible text of the rest of the document.
+func extractText(content []byte) []byte {
+	// html.Parse only returns errors from the reader,
+	// which never happens with bytes.Reader.
+	root, _ := html.Parse(bytes.NewReader(content))
+	var title, text []string
+	var walk func(n *html.Node, inTitle bool)
+	walk = func(n *html.Node, inTitle bool) {
+		switch n.Type {
+		case html.TextNode:
+			if inTitle {
+				title = append(title, strings.Fields(n.Data)...)
+			} else {
+				text = append(text, strings.Fields(n.Data)...)
+			}
+			return
+		case html.ElementNode:
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template:
+				return
+			case atom.Title:
+				inTitle = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inTitle)
+		}
+	}
+	walk(root, false)
+
+	var sb bytes.Buffer
+	sb.WriteString(strings.Join(title, " "))
+	sb.WriteString("\n")
+	sb.WriteString(strings.Join(text, " "))
+	return sb.Bytes()
+}
@@ -0,0 +1,69 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestExtractText(t *testing.T) {
+	content := []byte(`<!DOCTYPE html>
+<html>
+<head>
+  <title>Hello,
+    world!</title>
+  <style>body { color: red; }</style>
+  <script nonce="abc">var foo = 1;</script>
+</head>
+<body>
+  <!-- generated at 12:34 -->
+  <h1>Hello</h1>
+  <p>The quick <b>brown</b> fox.</p>
+  <noscript>Please enable JavaScript.</noscript>
+  <script>analytics("xyz");</script>
+</body>
+</html>`)
+	expected := "Hello, world!\nHello The quick brown fox."
+	if actual := string(extractText(content)); actual != expected {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
+
+func TestCompareText(t *testing.T) {
+	oldContent := []byte(`<html><head><title>Foo</title>
+<script nonce="0123456789abcdef">track("0123456789abcdef");</script>
+</head><body><p>Hello, world!</p></body></html>`)
+	newContent := []byte(`<html><head><title>Foo</title>
+<script nonce="fedcba9876543210">track("fedcba9876543210");</script>
+</head><body><p>Hello, world!</p></body></html>`)
+
+	t.Run("html", func(t *testing.T) {
+		scores, err := compare(context.Background(), oldContent, newContent, nil, "", Options{
+			Threshold:   0.95,
+			ExtractText: true,
+		})
+		if !errors.Is(err, ErrTooDissimilar) {
+			t.Errorf("Expected %v, got %v, scores: %+v", ErrTooDissimilar, err, scores)
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		scores, err := compare(context.Background(), oldContent, newContent, nil, "", Options{
+			Threshold:   0.95,
+			ExtractText: true,
+			TrustText:   true,
+		})
+		if err != nil {
+			t.Fatalf("compare returned error: %v", err)
+		}
+		if scores.Text != 1 {
+			t.Errorf("Expected text score to be 1, got %v", scores.Text)
+		}
+		if scores.HTML >= 0.95 {
+			t.Errorf("Expected html score to be less than 0.95, got %v", scores.HTML)
+		}
+		if scores.Similarity != scores.Text {
+			t.Errorf("Expected similarity to be the text score, got %+v", scores)
+		}
+	})
+}
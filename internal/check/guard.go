@@ -0,0 +1,169 @@
+package check
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// blockedNetworks are the networks AddressGuard refuses to connect to.
+var blockedNetworks = mustParseCIDRs(
+	// "This" network, 0.0.0.0 reaches the local host on most systems.
+	"0.0.0.0/8",
+	// RFC 1918 private networks.
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	// IETF protocol assignments,
+	// including the metadata service at 192.0.0.192 on Oracle Cloud.
+	"192.0.0.0/24",
+	// Benchmarking.
+	"198.18.0.0/15",
+	// Multicast, reserved and broadcast.
+	"224.0.0.0/3",
+	"255.255.255.255/32",
+	"ff00::/8",
+	// Local-use NAT64, mapped to arbitrary addresses.
+	"64:ff9b:1::/48",
+	// RFC 6598 carrier-grade NAT,
+	// also used by cloud metadata services (100.100.100.200 on Alibaba Cloud).
+	"100.64.0.0/10",
+	// Loopback.
+	"127.0.0.0/8",
+	"::1/128",
+	// Link-local,
+	// including the metadata services at 169.254.169.254 on most clouds.
+	"169.254.0.0/16",
+	"fe80::/10",
+	// Unique local addresses,
+	// including the metadata service at fd00:ec2::254 on AWS.
+	"fc00::/7",
+	// Unspecified.
+	"::/128",
+)
+
+// Networks embedding IPv4 addresses,
+// which are checked against blockedNetworks as IPv4 addresses.
+var (
+	// Well-known NAT64 prefix, with the IPv4 address in the last 4 bytes.
+	nat64Network = mustParseCIDRs("64:ff9b::/96")[0]
+	// IPv4-compatible addresses, with the IPv4 address in the last 4 bytes.
+	compatNetwork = mustParseCIDRs("::/96")[0]
+	// 6to4, with the IPv4 address in bytes 2-5.
+	sixToFourNetwork = mustParseCIDRs("2002::/16")[0]
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// AddressGuard refuses the connections to the private, loopback, link-local,
+// unique local and cloud metadata addresses,
+// so that the urls posted by anyone can't be used to reach into the network
+// the checker runs in.
+//
+// Its Control method is meant to be used as net.Dialer.Control,
+// which is called with the resolved address of every connection,
+// so the hosts reached through redirects and the DNS records changed between
+// the lookups are guarded as well.
+//
+// With a proxy, the connections are made to the proxy instead,
+// which has to be allowed explicitly and is trusted to guard the
+// destinations itself.
+type AddressGuard struct {
+	// The networks allowed even though they are blocked,
+	// e.g. the loopback network in tests.
+	Allow []*net.IPNet
+}
+
+// BlockedAddressError is the error returned when connecting to an address
+// blocked by AddressGuard.
+//
+// It matches ErrBlockedAddress with errors.Is.
+type BlockedAddressError struct {
+	// The resolved address, in host:port form.
+	Address string
+}
+
+func (e *BlockedAddressError) Error() string {
+	return fmt.Sprintf("connection to %q refused by the address guard", e.Address)
+}
+
+// Is implements the interface used by errors.Is.
+func (e *BlockedAddressError) Is(target error) bool {
+	return target == ErrBlockedAddress
+}
+
+// Control implements the signature of net.Dialer.Control.
+func (g *AddressGuard) Control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || g.blocked(ip) {
+		// The address is always resolved when Control is called,
+		// anything else is unexpected and refused.
+		return &BlockedAddressError{Address: address}
+	}
+	return nil
+}
+
+func (g *AddressGuard) blocked(ip net.IP) bool {
+	// IPv4-mapped IPv6 addresses are matched as IPv4.
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip4 := embeddedIPv4(ip); ip4 != nil && g.blocked(ip4) {
+		return true
+	}
+	for _, network := range g.Allow {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// embeddedIPv4 returns the IPv4 address embedded in the IPv6 address ip,
+// or nil if it doesn't embed one.
+func embeddedIPv4(ip net.IP) net.IP {
+	if len(ip) != net.IPv6len {
+		return nil
+	}
+	switch {
+	case nat64Network.Contains(ip), compatNetwork.Contains(ip):
+		return net.IPv4(ip[12], ip[13], ip[14], ip[15]).To4()
+	case sixToFourNetwork.Contains(ip):
+		return net.IPv4(ip[2], ip[3], ip[4], ip[5]).To4()
+	}
+	return nil
+}
+
+// guardedTransport returns a clone of http.DefaultTransport dialing with
+// guard.
+func guardedTransport(guard *AddressGuard) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   guard.Control,
+	}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,127 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestAddressGuard(t *testing.T) {
+	for _, c := range []struct {
+		ip      string
+		blocked bool
+	}{
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"172.31.255.255", true},
+		{"172.32.0.1", false},
+		{"192.168.1.1", true},
+		{"100.100.100.200", true},
+		{"127.0.0.1", true},
+		{"127.1.2.3", true},
+		{"::1", true},
+		{"169.254.169.254", true},
+		{"fe80::1", true},
+		{"fd00:ec2::254", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:169.254.169.254", true},
+		{"0.0.0.0", true},
+		{"::", true},
+		{"192.0.0.192", true},
+		{"198.18.0.1", true},
+		{"198.19.255.255", true},
+		{"198.20.0.1", false},
+		{"224.0.0.1", true},
+		{"255.255.255.255", true},
+		{"ff02::1", true},
+		{"64:ff9b::a00:1", true},
+		{"64:ff9b::a9fe:a9fe", true},
+		{"64:ff9b::808:808", false},
+		{"64:ff9b:1::808:808", true},
+		{"2002:a00:1::1", true},
+		{"2002:7f00:1::1", true},
+		{"2002:808:808::1", false},
+		{"::127.0.0.1", true},
+		{"::10.0.0.1", true},
+		{"::8.8.8.8", false},
+		{"8.8.8.8", false},
+		{"2001:4860:4860::8888", false},
+		{"::ffff:8.8.8.8", false},
+	} {
+		t.Run(c.ip, func(t *testing.T) {
+			var guard AddressGuard
+			err := guard.Control("tcp", net.JoinHostPort(c.ip, "80"), nil)
+			if c.blocked {
+				var blockedErr *BlockedAddressError
+				if !errors.As(err, &blockedErr) {
+					t.Fatalf("Expected *BlockedAddressError, got %v", err)
+				}
+				if !errors.Is(err, ErrBlockedAddress) {
+					t.Errorf("Expected %v, got %v", ErrBlockedAddress, err)
+				}
+			} else if err != nil {
+				t.Errorf("Expected allowed, got %v", err)
+			}
+		})
+	}
+
+	t.Run("allow", func(t *testing.T) {
+		guard := AddressGuard{
+			Allow: mustParseCIDRs("10.0.0.0/24"),
+		}
+		if err := guard.Control("tcp", "10.0.0.1:80", nil); err != nil {
+			t.Errorf("Expected 10.0.0.1 allowed, got %v", err)
+		}
+		if err := guard.Control("tcp", "10.0.1.1:80", nil); !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Expected 10.0.1.1 blocked, got %v", err)
+		}
+	})
+}
+
+func TestCheckerAddressGuard(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		var checker Checker
+		_, err := checker.Check(context.Background(), "http://169.254.169.254/latest/meta-data/")
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Expected %v, got %v", ErrBlockedAddress, err)
+		}
+	})
+
+	t.Run("resolved", func(t *testing.T) {
+		server := httptest.NewServer(staticHandler("<p>Hello, world!</p>"))
+		t.Cleanup(server.Close)
+		u, _ := url.Parse(server.URL)
+		u.Host = net.JoinHostPort("localhost", u.Port())
+
+		var checker Checker
+		_, _, err := checker.fetch(context.Background(), u)
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Expected %v, got %v", ErrBlockedAddress, err)
+		}
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, port, _ := net.SplitHostPort(r.Host)
+			http.Redirect(w, r, "http://"+net.JoinHostPort("127.0.0.2", port)+"/", http.StatusFound)
+		}))
+		t.Cleanup(server.Close)
+		u, _ := url.Parse(server.URL)
+
+		checker := Checker{
+			Client: &http.Client{
+				Transport: guardedTransport(&AddressGuard{
+					Allow: mustParseCIDRs("127.0.0.1/32"),
+				}),
+			},
+		}
+		_, _, err := checker.fetch(context.Background(), u)
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("Expected %v, got %v", ErrBlockedAddress, err)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package check
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// HSTS is the HTTP Strict Transport Security[1] status of a url.
+//
+// [1]: https://tools.ietf.org/html/rfc6797
+type HSTS struct {
+	// The Strict-Transport-Security header of the https response,
+	// after following the redirects.
+	Header string `json:"header,omitempty"`
+
+	// The directives parsed from Header.
+	//
+	// They are all zero values when Header is empty or invalid.
+	MaxAge            int64 `json:"max_age,omitempty"` // in seconds
+	IncludeSubDomains bool  `json:"include_subdomains,omitempty"`
+	Preload           bool  `json:"preload,omitempty"`
+
+	// Whether the host of the http url is in Checker.PreloadList,
+	// which makes browsers using that list visit it over https directly.
+	//
+	// It's always false when Checker.PreloadList is nil.
+	Preloaded bool `json:"preloaded"`
+}
+
+// Errors returned by ParseHSTS.
+var (
+	ErrHSTSNoMaxAge    = errors.New("hsts: missing max-age directive")
+	ErrHSTSDuplicate   = errors.New("hsts: duplicate directive")
+	ErrHSTSInvalidAge  = errors.New("hsts: invalid max-age value")
+	ErrHSTSEmptyHeader = errors.New("hsts: empty header")
+)
+
+// ParseHSTS parses the value of a Strict-Transport-Security header.
+//
+// Only the max-age, includeSubDomains and preload directives are parsed,
+// unknown directives are ignored as required by RFC 6797.
+// The returned HSTS has Header set but not Preloaded.
+func ParseHSTS(header string) (HSTS, error) {
+	hsts := HSTS{Header: header}
+	if strings.TrimSpace(header) == "" {
+		return hsts, ErrHSTSEmptyHeader
+	}
+	var hasMaxAge bool
+	seen := make(map[string]bool)
+	for _, directive := range strings.Split(header, ";") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		name, value := directive, ""
+		if i := strings.IndexByte(directive, '='); i >= 0 {
+			name = strings.TrimSpace(directive[:i])
+			value = strings.Trim(strings.TrimSpace(directive[i+1:]), `"`)
+		}
+		name = strings.ToLower(name)
+		if seen[name] {
+			return HSTS{Header: header}, fmt.Errorf("%w: %q", ErrHSTSDuplicate, name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "max-age":
+			age, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || age < 0 {
+				return HSTS{Header: header}, fmt.Errorf("%w: %q", ErrHSTSInvalidAge, value)
+			}
+			hsts.MaxAge = age
+			hasMaxAge = true
+		case "includesubdomains":
+			hsts.IncludeSubDomains = true
+		case "preload":
+			hsts.Preload = true
+		}
+	}
+	if !hasMaxAge {
+		return HSTS{Header: header}, ErrHSTSNoMaxAge
+	}
+	return hsts, nil
+}
+
+// PreloadList is a list of hosts that browsers always visit over https.
+//
+// A PreloadList is safe for concurrent use after created.
+type PreloadList struct {
+	// Map from the host to whether its subdomains are also included.
+	hosts map[string]bool
+}
+
+// ParsePreloadList parses a PreloadList in either the format of Chromium's
+// transport_security_state_static.json[1],
+// or the compact form "make update-hsts-preload" generates from it,
+// which is a JSON object mapping the hosts to whether their subdomains are
+// also included.
+//
+// Full line comments starting with "//" are allowed,
+// and only the entries with "force-https" mode are used from the Chromium
+// format.
+//
+// [1]: https://chromium.googlesource.com/chromium/src/+/main/net/http/transport_security_state_static.json
+func ParsePreloadList(r io.Reader) (*PreloadList, error) {
+	var stripped bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	// The compact form is a single line.
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if bytes.HasPrefix(bytes.TrimSpace(line), []byte("//")) {
+			continue
+		}
+		stripped.Write(line)
+		stripped.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read hsts preload list: %w", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(stripped.Bytes(), &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse hsts preload list: %w", err)
+	}
+	entriesJSON, ok := fields["entries"]
+	if !ok {
+		var hosts map[string]bool
+		if err := json.Unmarshal(stripped.Bytes(), &hosts); err != nil {
+			return nil, fmt.Errorf("failed to parse compact hsts preload list: %w", err)
+		}
+		p := &PreloadList{
+			hosts: make(map[string]bool, len(hosts)),
+		}
+		for host, includeSubdomains := range hosts {
+			p.hosts[strings.ToLower(host)] = includeSubdomains
+		}
+		return p, nil
+	}
+
+	var entries []struct {
+		Name              string `json:"name"`
+		Mode              string `json:"mode"`
+		IncludeSubdomains bool   `json:"include_subdomains"`
+	}
+	if err := json.Unmarshal(entriesJSON, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse hsts preload list: %w", err)
+	}
+	p := &PreloadList{
+		hosts: make(map[string]bool, len(entries)),
+	}
+	for _, entry := range entries {
+		if entry.Mode != "force-https" {
+			continue
+		}
+		p.hosts[strings.ToLower(entry.Name)] = entry.IncludeSubdomains
+	}
+	return p, nil
+}
+
+// Len returns the number of hosts in the list.
+func (p *PreloadList) Len() int {
+	return len(p.hosts)
+}
+
+// Contains reports whether host is in the list,
+// either directly or as a subdomain of a host with its subdomains included.
+func (p *PreloadList) Contains(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if _, ok := p.hosts[host]; ok {
+		return true
+	}
+	for i := strings.IndexByte(host, '.'); i >= 0; i = strings.IndexByte(host, '.') {
+		host = host[i+1:]
+		if p.hosts[host] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseHSTS(t *testing.T) {
+	for _, c := range []struct {
+		header   string
+		expected HSTS
+		err      error
+	}{
+		{
+			header: "max-age=31536000",
+			expected: HSTS{
+				MaxAge: 31536000,
+			},
+		},
+		{
+			header: `Max-Age="63072000"; includeSubDomains; preload`,
+			expected: HSTS{
+				MaxAge:            63072000,
+				IncludeSubDomains: true,
+				Preload:           true,
+			},
+		},
+		{
+			header: "includesubdomains;max-age=0;unknown=foo;",
+			expected: HSTS{
+				IncludeSubDomains: true,
+			},
+		},
+		{
+			header: "",
+			err:    ErrHSTSEmptyHeader,
+		},
+		{
+			header: "includeSubDomains; preload",
+			err:    ErrHSTSNoMaxAge,
+		},
+		{
+			header: "max-age=1; max-age=2",
+			err:    ErrHSTSDuplicate,
+		},
+		{
+			header: "max-age=-1",
+			err:    ErrHSTSInvalidAge,
+		},
+		{
+			header: "max-age=forever",
+			err:    ErrHSTSInvalidAge,
+		},
+	} {
+		t.Run(c.header, func(t *testing.T) {
+			c.expected.Header = c.header
+			hsts, err := ParseHSTS(c.header)
+			if !errors.Is(err, c.err) {
+				t.Errorf("Expected error %v, got %v", c.err, err)
+			}
+			if hsts != c.expected {
+				t.Errorf("Expected %+v, got %+v", c.expected, hsts)
+			}
+		})
+	}
+}
+
+func TestPreloadList(t *testing.T) {
+	const list = `// Comments are allowed.
+{
+  "pinsets": [],
+  "entries": [
+    // Comments are allowed here too.
+    { "name": "example.com", "policy": "custom", "mode": "force-https", "include_subdomains": true },
+    { "name": "example.org", "policy": "custom", "mode": "force-https" },
+    { "name": "example.net", "policy": "custom", "include_subdomains_for_pinning": true }
+  ]
+}`
+	p, err := ParsePreloadList(strings.NewReader(list))
+	if err != nil {
+		t.Fatalf("ParsePreloadList returned error: %v", err)
+	}
+	if p.Len() != 2 {
+		t.Errorf("Expected 2 hosts, got %d", p.Len())
+	}
+	for host, expected := range map[string]bool{
+		"example.com":         true,
+		"EXAMPLE.com.":        true,
+		"www.example.com":     true,
+		"a.b.example.com":     true,
+		"example.org":         true,
+		"www.example.org":     false,
+		"example.net":         false,
+		"www.example.net":     false,
+		"notexample.com":      false,
+		"example.com.invalid": false,
+	} {
+		if actual := p.Contains(host); actual != expected {
+			t.Errorf("Contains(%q) expected %v, got %v", host, expected, actual)
+		}
+	}
+
+	if _, err := ParsePreloadList(strings.NewReader("{")); err == nil {
+		t.Error("Expected error on invalid list")
+	}
+
+	t.Run("compact", func(t *testing.T) {
+		p, err := ParsePreloadList(strings.NewReader(`{"example.com":true,"Example.ORG":false}`))
+		if err != nil {
+			t.Fatalf("ParsePreloadList returned error: %v", err)
+		}
+		for host, expected := range map[string]bool{
+			"www.example.com": true,
+			"example.org":     true,
+			"www.example.org": false,
+			"example.net":     false,
+		} {
+			if actual := p.Contains(host); actual != expected {
+				t.Errorf("Contains(%q) expected %v, got %v", host, expected, actual)
+			}
+		}
+	})
+}
+
+func TestCheckHSTS(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+	preload, err := ParsePreloadList(strings.NewReader(`{"entries": [
+		{"name": "` + testHost + `", "mode": "force-https"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := newTestChecker(
+		t,
+		staticHandler(content),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("strict-transport-security", "max-age=300; includeSubDomains")
+			w.Write([]byte(content))
+		}),
+	)
+	checker.PreloadList = preload
+	result, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	expected := HSTS{
+		Header:            "max-age=300; includeSubDomains",
+		MaxAge:            300,
+		IncludeSubDomains: true,
+		Preloaded:         true,
+	}
+	if result.HSTS == nil || *result.HSTS != expected {
+		t.Errorf("Expected hsts %+v, got %+v", expected, result.HSTS)
+	}
+}
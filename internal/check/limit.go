@@ -0,0 +1,428 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HostLimit is the budget of the requests to a host.
+type HostLimit struct {
+	// The sustained number of requests per second,
+	// or unlimited when it's not positive.
+	Rate float64
+
+	// The number of requests that can be made at once above Rate,
+	// at least 1.
+	Burst int
+
+	// The max number of requests in flight,
+	// or unlimited when it's not positive.
+	//
+	// A request is in flight until its response body is closed.
+	// All the requests of the same Checker.Check share one slot,
+	// so that the http and https sides of a check never compete with each
+	// other.
+	MaxConcurrent int
+}
+
+// Limiter limits the requests Checker makes to every host,
+// so that a burst of urls on the same host doesn't hammer the origin.
+//
+// The http and https requests to a host share the same budget,
+// and every redirect counts as a request.
+//
+// A Limiter is safe for concurrent use,
+// as long as its fields are not modified after the first use.
+type Limiter struct {
+	// The limit of the hosts not in Domains.
+	Default HostLimit
+
+	// The limits overriding Default,
+	// keyed by the domains they apply to, including their subdomains.
+	// The longest matching domain wins.
+	Domains map[string]HostLimit
+
+	// The max time a request waits for the budget of its host,
+	// after which it fails with a *RateLimitError.
+	// A request never waits past the deadline of its context either.
+	//
+	// When it's not positive,
+	// requests fail immediately when the budget is exhausted.
+	MaxWait time.Duration
+
+	mu        sync.Mutex
+	hosts     map[string]*hostBudget
+	lastSweep time.Time
+}
+
+// limiterSweepInterval is how often Limiter drops the idle hosts,
+// so that it only keeps the hosts with requests recently.
+const limiterSweepInterval = time.Minute
+
+// RateLimitError is the error returned when the budget of a host is exhausted
+// for longer than Limiter.MaxWait.
+//
+// It matches ErrRateLimited with errors.Is.
+type RateLimitError struct {
+	Host string
+
+	// Whether it's MaxConcurrent exhausted, instead of Rate.
+	Concurrency bool
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Concurrency {
+		return fmt.Sprintf("too many requests in flight to host %q", e.Host)
+	}
+	return fmt.Sprintf("too many requests per second to host %q", e.Host)
+}
+
+// Is implements the interface used by errors.Is.
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// hostBudget is the state of the budget of a host.
+type hostBudget struct {
+	limit HostLimit
+
+	// Guarded by Limiter.mu.
+	tokens float64
+	last   time.Time
+	// The number of requests holding or waiting for the budget.
+	users int
+
+	// nil when the concurrency is unlimited.
+	slots chan struct{}
+}
+
+func (l *Limiter) limitOf(host string) HostLimit {
+	var (
+		limit   = l.Default
+		longest = -1
+	)
+	for domain, domainLimit := range l.Domains {
+		domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > longest {
+			limit = domainLimit
+			longest = len(domain)
+		}
+	}
+	return limit
+}
+
+// slotShare is the slots held by the requests of the same check.
+type slotShare struct {
+	mu    sync.Mutex
+	slots map[*hostBudget]*sharedSlot
+}
+
+// sharedSlot is a slot of a host shared by the requests of the same check.
+//
+// Its fields are guarded by slotShare.mu.
+type sharedSlot struct {
+	// Closed when the request taking the slot is done waiting for it,
+	// either with the slot taken or not.
+	taken chan struct{}
+
+	// The number of requests holding the slot,
+	// 0 while it's still being taken.
+	held int
+}
+
+type slotShareKey struct{}
+
+// withSlotShare returns a copy of ctx,
+// the requests with which share their slots of the hosts.
+func withSlotShare(ctx context.Context) context.Context {
+	return context.WithValue(ctx, slotShareKey{}, &slotShare{
+		slots: make(map[*hostBudget]*sharedSlot),
+	})
+}
+
+// take takes the slot of b shared by the requests of the check.
+//
+// Only the first request waits for the slot with waitSlot,
+// the others wait for it to be done without holding s.mu,
+// and take the slot themselves when it failed.
+func (s *slotShare) take(ctx context.Context, l *Limiter, b *hostBudget, host string, start time.Time) error {
+	for {
+		s.mu.Lock()
+		slot := s.slots[b]
+		if slot == nil {
+			slot = &sharedSlot{
+				taken: make(chan struct{}),
+			}
+			s.slots[b] = slot
+			s.mu.Unlock()
+
+			err := l.waitSlot(ctx, b, host, start)
+			s.mu.Lock()
+			if err != nil {
+				delete(s.slots, b)
+			} else {
+				slot.held++
+			}
+			close(slot.taken)
+			s.mu.Unlock()
+			return err
+		}
+		if slot.held > 0 {
+			slot.held++
+			s.mu.Unlock()
+			return nil
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-slot.taken:
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return &RateLimitError{Host: host, Concurrency: true}
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// put releases the slot of b taken by take,
+// freeing it when no other request of the check holds it.
+func (s *slotShare) put(b *hostBudget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot := s.slots[b]
+	slot.held--
+	if slot.held == 0 {
+		delete(s.slots, b)
+		<-b.slots
+	}
+}
+
+// reserve takes a token from the bucket of host,
+// returning how long the caller has to wait before using it.
+//
+// When the wait would be longer than MaxWait,
+// no token is taken and ok is false.
+func (l *Limiter) reserve(host string, now time.Time) (b *hostBudget, wait time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.hosts == nil {
+		l.hosts = make(map[string]*hostBudget)
+	}
+	if now.Sub(l.lastSweep) >= limiterSweepInterval {
+		l.sweep(now)
+	}
+	b = l.hosts[host]
+	if b == nil {
+		b = &hostBudget{
+			limit: l.limitOf(host),
+			last:  now,
+		}
+		if b.limit.Burst < 1 {
+			b.limit.Burst = 1
+		}
+		b.tokens = float64(b.limit.Burst)
+		if b.limit.MaxConcurrent > 0 {
+			b.slots = make(chan struct{}, b.limit.MaxConcurrent)
+		}
+		l.hosts[host] = b
+	}
+	if b.limit.Rate <= 0 {
+		b.users++
+		return b, 0, true
+	}
+
+	b.refill(now)
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+	}
+	if wait > 0 && wait > l.MaxWait {
+		return b, wait, false
+	}
+	// The tokens go negative when the following requests are queued.
+	b.tokens--
+	b.users++
+	return b, wait, true
+}
+
+func (b *hostBudget) refill(now time.Time) {
+	b.tokens = math.Min(
+		float64(b.limit.Burst),
+		b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate,
+	)
+	b.last = now
+}
+
+// refund puts the token taken by reserve back to b,
+// for a request that gave up waiting.
+func (l *Limiter) refund(b *hostBudget) {
+	if b.limit.Rate <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b.tokens++
+}
+
+// done marks a request that reserved b as finished.
+func (l *Limiter) done(b *hostBudget) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b.users--
+}
+
+// sweep drops the hosts without requests and with their buckets full,
+// which are the same as new ones.
+//
+// It must be called with l.mu held.
+func (l *Limiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for host, b := range l.hosts {
+		if b.users > 0 {
+			continue
+		}
+		if b.limit.Rate > 0 {
+			b.refill(now)
+			if b.tokens < float64(b.limit.Burst) {
+				continue
+			}
+		}
+		delete(l.hosts, host)
+	}
+}
+
+// acquire waits for the budget of host,
+// returning the function to call after the request is done.
+//
+// It never waits past the deadline of ctx:
+// when the budget is not available before that,
+// it fails with a *RateLimitError immediately instead.
+func (l *Limiter) acquire(ctx context.Context, host string) (release func(), err error) {
+	host = strings.ToLower(host)
+	start := time.Now()
+	b, wait, ok := l.reserve(host, start)
+	if !ok {
+		return nil, &RateLimitError{Host: host}
+	}
+	defer func() {
+		if err != nil {
+			// The request is not made, so it doesn't count.
+			l.refund(b)
+			l.done(b)
+		}
+	}()
+	deadline, hasDeadline := ctx.Deadline()
+	if wait > 0 {
+		if hasDeadline && start.Add(wait).After(deadline) {
+			return nil, &RateLimitError{Host: host}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+
+	share, _ := ctx.Value(slotShareKey{}).(*slotShare)
+	if b.slots != nil {
+		if share == nil {
+			if err := l.waitSlot(ctx, b, host, start); err != nil {
+				return nil, err
+			}
+		} else if err := share.take(ctx, l, b, host, start); err != nil {
+			return nil, err
+		}
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			switch {
+			case b.slots == nil:
+			case share == nil:
+				<-b.slots
+			default:
+				share.put(b)
+			}
+			l.done(b)
+		})
+	}, nil
+}
+
+// waitSlot takes a slot of b,
+// waiting for MaxWait since start at most.
+func (l *Limiter) waitSlot(ctx context.Context, b *hostBudget, host string, start time.Time) error {
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	remaining := l.MaxWait - time.Since(start)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < remaining {
+		remaining = time.Until(deadline)
+	}
+	if remaining <= 0 {
+		return &RateLimitError{Host: host, Concurrency: true}
+	}
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case b.slots <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return &RateLimitError{Host: host, Concurrency: true}
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return &RateLimitError{Host: host, Concurrency: true}
+		}
+		return ctx.Err()
+	}
+}
+
+// limitedTransport is an http.RoundTripper limiting the requests to base with
+// limiter.
+type limitedTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	release, err := t.limiter.acquire(req.Context(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &releasingBody{
+		ReadCloser: resp.Body,
+		release:    release,
+	}
+	return resp, nil
+}
+
+// releasingBody calls release when the body is closed.
+type releasingBody struct {
+	io.ReadCloser
+
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,335 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiterLimitOf(t *testing.T) {
+	l := &Limiter{
+		Default: HostLimit{Rate: 1},
+		Domains: map[string]HostLimit{
+			"github.io":       {Rate: 2},
+			"fishy.github.io": {Rate: 3},
+		},
+	}
+	for host, expected := range map[string]float64{
+		"example.com":           1,
+		"github.io":             2,
+		"foo.github.io":         2,
+		"fishy.github.io":       3,
+		"www.fishy.github.io":   3,
+		"notgithub.io":          1,
+		"github.io.example.com": 1,
+	} {
+		if got := l.limitOf(host).Rate; got != expected {
+			t.Errorf("limitOf(%q).Rate = %v, expected %v", host, got, expected)
+		}
+	}
+}
+
+func TestLimiterReserve(t *testing.T) {
+	now := time.Now()
+	const host = "example.com"
+
+	t.Run("skip", func(t *testing.T) {
+		l := &Limiter{Default: HostLimit{Rate: 10, Burst: 2}}
+		for i := 0; i < 2; i++ {
+			if _, wait, ok := l.reserve(host, now); !ok || wait != 0 {
+				t.Fatalf("#%d: reserve = %v, %v, expected the burst", i, wait, ok)
+			}
+		}
+		if _, _, ok := l.reserve(host, now); ok {
+			t.Error("Expected the budget exhausted")
+		}
+		if _, wait, ok := l.reserve(host, now.Add(100*time.Millisecond)); !ok || wait != 0 {
+			t.Errorf("reserve = %v, %v, expected the budget refilled", wait, ok)
+		}
+		if _, _, ok := l.reserve("example.org", now); !ok {
+			t.Error("Expected other hosts not affected")
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		l := &Limiter{
+			Default: HostLimit{Rate: 10},
+			MaxWait: 250 * time.Millisecond,
+		}
+		for i, expected := range []time.Duration{
+			0,
+			100 * time.Millisecond,
+			200 * time.Millisecond,
+		} {
+			_, wait, ok := l.reserve(host, now)
+			if !ok {
+				t.Fatalf("#%d: Expected the request queued", i)
+			}
+			if diff := wait - expected; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("#%d: wait = %v, expected %v", i, wait, expected)
+			}
+		}
+		if _, _, ok := l.reserve(host, now); ok {
+			t.Error("Expected the budget exhausted beyond MaxWait")
+		}
+	})
+}
+
+func TestLimiterSweep(t *testing.T) {
+	now := time.Now()
+	l := &Limiter{
+		Default: HostLimit{Rate: 1, Burst: 2},
+		Domains: map[string]HostLimit{
+			"drained.example.com": {Rate: 0.01, Burst: 2},
+		},
+	}
+
+	idle, _, _ := l.reserve("idle.example.com", now)
+	l.done(idle)
+	l.reserve("busy.example.com", now)
+	drained, _, _ := l.reserve("drained.example.com", now)
+	l.reserve("drained.example.com", now)
+	l.done(drained)
+	l.done(drained)
+
+	// The bucket of idle is full again by now, but not the one of drained.
+	later := now.Add(limiterSweepInterval)
+	l.reserve("example.org", later)
+	for host, expected := range map[string]bool{
+		"idle.example.com":    false,
+		"busy.example.com":    true,
+		"drained.example.com": true,
+		"example.org":         true,
+	} {
+		if _, ok := l.hosts[host]; ok != expected {
+			t.Errorf("%s kept = %v, expected %v", host, ok, expected)
+		}
+	}
+}
+
+func TestLimiterAcquire(t *testing.T) {
+	const host = "example.com"
+
+	t.Run("deadline", func(t *testing.T) {
+		l := &Limiter{
+			Default: HostLimit{Rate: 1, MaxConcurrent: 1},
+			MaxWait: time.Minute,
+		}
+		release, err := l.acquire(context.Background(), host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err = l.acquire(ctx, host)
+		var limitErr *RateLimitError
+		if !errors.As(err, &limitErr) || limitErr.Concurrency {
+			t.Errorf("Expected rate exhausted, got %v", err)
+		}
+		if took := time.Since(start); took > 50*time.Millisecond {
+			t.Errorf("Expected to fail without waiting, took %v", took)
+		}
+		if tokens := l.hosts[host].tokens; tokens < -0.01 {
+			t.Errorf("tokens = %v, expected the token refunded", tokens)
+		}
+
+		// The rate allows it, but the slot is still taken.
+		l.hosts[host].tokens = 1
+		_, err = l.acquire(ctx, host)
+		if !errors.As(err, &limitErr) || !limitErr.Concurrency {
+			t.Errorf("Expected concurrency exhausted, got %v", err)
+		}
+		if took := time.Since(start); took > time.Second {
+			t.Errorf("Expected to wait until the deadline at most, took %v", took)
+		}
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		l := &Limiter{
+			Default: HostLimit{Rate: 1},
+			MaxWait: time.Minute,
+		}
+		release, err := l.acquire(context.Background(), host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		if _, err := l.acquire(ctx, host); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+		if tokens := l.hosts[host].tokens; tokens < -0.01 {
+			t.Errorf("tokens = %v, expected the token refunded", tokens)
+		}
+	})
+}
+
+func TestLimiterShare(t *testing.T) {
+	l := &Limiter{
+		Default: HostLimit{MaxConcurrent: 1},
+		MaxWait: time.Second,
+	}
+	ctx := withSlotShare(context.Background())
+
+	t.Run("siblings", func(t *testing.T) {
+		releases := make([]func(), 3)
+		for i := range releases {
+			release, err := l.acquire(ctx, "example.com")
+			if err != nil {
+				t.Fatalf("#%d: %v", i, err)
+			}
+			releases[i] = release
+		}
+		if _, err := l.acquire(context.Background(), "example.com"); !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected other checks rate limited, got %v", err)
+		}
+		for _, release := range releases {
+			release()
+		}
+		release, err := l.acquire(context.Background(), "example.com")
+		if err != nil {
+			t.Fatalf("Expected the slot freed, got %v", err)
+		}
+		release()
+	})
+
+	t.Run("cross-host", func(t *testing.T) {
+		// Another check holds the slot of example.org.
+		other, err := l.acquire(context.Background(), "example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release, err := l.acquire(ctx, "example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A sibling waits for the slot of example.org.
+		sibling := make(chan error, 1)
+		go func() {
+			release, err := l.acquire(ctx, "example.org")
+			if err == nil {
+				release()
+			}
+			sibling <- err
+		}()
+		time.Sleep(50 * time.Millisecond)
+
+		start := time.Now()
+		release()
+		if took := time.Since(start); took > 100*time.Millisecond {
+			t.Errorf("Expected the release not blocked by the sibling, took %v", took)
+		}
+		if release, err := l.acquire(context.Background(), "example.com"); err != nil {
+			t.Errorf("Expected the slot of example.com freed, got %v", err)
+		} else {
+			release()
+		}
+
+		other()
+		if err := <-sibling; err != nil {
+			t.Errorf("Expected the sibling to get the slot, got %v", err)
+		}
+	})
+}
+
+func TestCheckerLimiter(t *testing.T) {
+	const (
+		content = "<p>Hello, world!</p>"
+		latency = 100 * time.Millisecond
+	)
+
+	t.Run("share", func(t *testing.T) {
+		checker := newTestChecker(
+			t,
+			slowHandler(latency, staticHandler(content)),
+			slowHandler(latency, staticHandler(content)),
+		)
+		checker.Limiter = &Limiter{
+			Default: HostLimit{MaxConcurrent: 1},
+		}
+		// Both sides of the check are on the same host.
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The two sides are still concurrent.
+		if result.Took >= 2*latency {
+			t.Errorf("Expected the check to take less than %v, took %v", 2*latency, result.Took)
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		checker := newTestChecker(
+			t,
+			slowHandler(latency, staticHandler(content)),
+			slowHandler(latency, staticHandler(content)),
+		)
+		checker.Limiter = &Limiter{
+			Default: HostLimit{MaxConcurrent: 1},
+		}
+		checker.Cache = &Cache{}
+		first := make(chan error, 1)
+		go func() {
+			_, err := checker.Check(context.Background(), "http://"+testHost+"/first")
+			first <- err
+		}()
+		time.Sleep(latency / 2)
+
+		_, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		var limitErr *RateLimitError
+		if !errors.As(err, &limitErr) {
+			t.Fatalf("Expected *RateLimitError, got %v", err)
+		}
+		if !errors.Is(err, ErrRateLimited) {
+			t.Errorf("Expected %v, got %v", ErrRateLimited, err)
+		}
+		if limitErr.Host != testHost || !limitErr.Concurrency {
+			t.Errorf("Expected concurrency of %q exhausted, got %+v", testHost, limitErr)
+		}
+		u, _ := url.Parse("http://" + testHost + "/")
+		if _, _, ok := checker.Cache.lookup(u); ok {
+			t.Error("Expected rate limited results not cached")
+		}
+		if err := <-first; err != nil {
+			t.Errorf("Expected the first check to succeed, got %v", err)
+		}
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		checker := newTestChecker(
+			t,
+			slowHandler(latency, staticHandler(content)),
+			slowHandler(latency, staticHandler(content)),
+		)
+		checker.Limiter = &Limiter{
+			Default: HostLimit{MaxConcurrent: 1},
+			MaxWait: 10 * time.Second,
+		}
+		start := time.Now()
+		var wg sync.WaitGroup
+		for _, path := range []string{"/a", "/b"} {
+			path := path
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := checker.Check(context.Background(), "http://"+testHost+path); err != nil {
+					t.Errorf("%s: %v", path, err)
+				}
+			}()
+		}
+		wg.Wait()
+		// The two checks are serialized.
+		if took := time.Since(start); took < 2*latency {
+			t.Errorf("Expected the checks to take at least %v, took %v", 2*latency, took)
+		}
+	})
+}
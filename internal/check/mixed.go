@@ -0,0 +1,129 @@
+package check
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// MixedContent is the counts of the resources an https page loads over http.
+//
+// As only the content up to the read limit is scanned,
+// the counts are the lower bounds for long pages.
+type MixedContent struct {
+	// Scripts, iframes, stylesheets and form actions,
+	// which can modify the whole page.
+	Active int `json:"active"`
+
+	// Images, audios and videos,
+	// which can only modify themselves.
+	Passive int `json:"passive"`
+}
+
+// scanMixedContent counts the mixed content in html content served from
+// https url base.
+func scanMixedContent(content []byte, base *url.URL) MixedContent {
+	// html.Parse only returns errors from the reader,
+	// which never happens with bytes.Reader.
+	root, _ := html.Parse(bytes.NewReader(content))
+	if href, ok := findBase(root); ok {
+		if u, err := base.Parse(href); err == nil {
+			base = u
+		}
+	}
+
+	var mc MixedContent
+	isHTTP := func(ref string) bool {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			return false
+		}
+		u, err := base.Parse(ref)
+		return err == nil && u.Scheme == "http"
+	}
+	var walk func(n *html.Node, inMedia bool)
+	walk = func(n *html.Node, inMedia bool) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Script, atom.Iframe:
+				if isHTTP(attr(n, "src")) {
+					mc.Active++
+				}
+			case atom.Link:
+				if isStylesheet(attr(n, "rel")) && isHTTP(attr(n, "href")) {
+					mc.Active++
+				}
+			case atom.Form:
+				if isHTTP(attr(n, "action")) {
+					mc.Active++
+				}
+			case atom.Img:
+				if isHTTP(attr(n, "src")) || srcsetHasHTTP(attr(n, "srcset"), isHTTP) {
+					mc.Passive++
+				}
+			case atom.Audio, atom.Video:
+				if isHTTP(attr(n, "src")) || isHTTP(attr(n, "poster")) {
+					mc.Passive++
+				}
+				inMedia = true
+			case atom.Source:
+				if inMedia && isHTTP(attr(n, "src")) {
+					mc.Passive++
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inMedia)
+		}
+	}
+	walk(root, false)
+	return mc
+}
+
+// findBase returns the non-empty href of the first base element with one.
+func findBase(n *html.Node) (string, bool) {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Base {
+		if href := attr(n, "href"); href != "" {
+			return href, true
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if href, ok := findBase(c); ok {
+			return href, true
+		}
+	}
+	return "", false
+}
+
+// attr returns the value of the attribute key of n,
+// or empty string if n doesn't have it.
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func isStylesheet(rel string) bool {
+	for _, r := range strings.Fields(rel) {
+		if strings.EqualFold(r, "stylesheet") {
+			return true
+		}
+	}
+	return false
+}
+
+// srcsetHasHTTP reports whether any of the candidates in srcset is http.
+func srcsetHasHTTP(srcset string, isHTTP func(string) bool) bool {
+	for _, candidate := range strings.Split(srcset, ",") {
+		if fields := strings.Fields(candidate); len(fields) > 0 && isHTTP(fields[0]) {
+			return true
+		}
+	}
+	return false
+}
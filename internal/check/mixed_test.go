@@ -0,0 +1,105 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+)
+
+func TestScanMixedContent(t *testing.T) {
+	base, err := url.Parse("https://" + testHost + "/foo/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		label    string
+		content  string
+		expected MixedContent
+	}{
+		{
+			label: "none",
+			content: `<html><head>
+<script src="/app.js"></script>
+<script src="//cdn.example.com/lib.js"></script>
+<link rel="stylesheet" href="https://cdn.example.com/style.css">
+</head><body>
+<a href="http://example.org/">links are fine</a>
+<link rel="canonical" href="http://` + testHost + `/foo/">
+<img src="logo.png">
+<script>var u = "http://example.org/";</script>
+</body></html>`,
+		},
+		{
+			label: "active",
+			content: `<html><head>
+<script src="http://cdn.example.com/lib.js"></script>
+<link rel="Preload StyleSheet" href="http://cdn.example.com/style.css">
+</head><body>
+<iframe src="HTTP://example.org/embed"></iframe>
+<form action="http://` + testHost + `/login"></form>
+<form action="/search"></form>
+</body></html>`,
+			expected: MixedContent{Active: 4},
+		},
+		{
+			label: "passive",
+			content: `<html><body>
+<img src="http://example.org/a.png">
+<img src="b.png" srcset="b-2x.png 2x, http://example.org/b-3x.png 3x">
+<video poster="http://example.org/poster.jpg"></video>
+<audio><source src="http://example.org/a.mp3"><source src="a.ogg"></audio>
+<picture><source src="http://example.org/not-media.png"></picture>
+</body></html>`,
+			expected: MixedContent{Passive: 4},
+		},
+		{
+			label: "base",
+			content: `<html><head>
+<base href="http://static.example.com/">
+<script src="app.js"></script>
+<script src="https://cdn.example.com/lib.js"></script>
+</head><body><img src="logo.png"></body></html>`,
+			expected: MixedContent{Active: 1, Passive: 1},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			actual := scanMixedContent([]byte(c.content), base)
+			if actual != c.expected {
+				t.Errorf("Expected %+v, got %+v", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckMixedContent(t *testing.T) {
+	const content = `<p>Hello, world!</p><script src="http://example.org/app.js"></script>`
+
+	for _, c := range []struct {
+		label string
+		block bool
+		err   error
+	}{
+		{
+			label: "allowed",
+		},
+		{
+			label: "blocked",
+			block: true,
+			err:   ErrMixedContent,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			checker := newTestChecker(t, staticHandler(content), staticHandler(content))
+			checker.BlockActiveMixedContent = c.block
+			result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+			if !errors.Is(err, c.err) {
+				t.Errorf("Expected error %v, got %v", c.err, err)
+			}
+			expected := MixedContent{Active: 1}
+			if result.MixedContent == nil || *result.MixedContent != expected {
+				t.Errorf("Expected mixed content %+v, got %+v", expected, result.MixedContent)
+			}
+		})
+	}
+}
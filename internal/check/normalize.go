@@ -0,0 +1,29 @@
+package check
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// selfLinkTmpl is the template of the regular expression matching self
+// referencing links to a host.
+//
+// It matches "http://host", "https://host" and "//host" case insensitively,
+// as well as their forms with escaped slashes as seen in JSON
+// ("https:\/\/host").
+const selfLinkTmpl = `(?i)(?:https?:)?(?://|\\/\\/)%s\b`
+
+// normalizeSelfLinks rewrites the self referencing links to host in content
+// into the scheme relative form ("//host").
+//
+// Many sites render absolute links with the scheme of the current request,
+// so without normalization the same page served over http and https differs
+// in every self referencing link.
+func normalizeSelfLinks(content []byte, host string) []byte {
+	if host == "" {
+		return content
+	}
+	quoted := regexp.QuoteMeta(host)
+	re := regexp.MustCompile(fmt.Sprintf(selfLinkTmpl, quoted))
+	return re.ReplaceAllLiteral(content, []byte("//"+host))
+}
@@ -0,0 +1,77 @@
+package check
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNormalizeSelfLinks(t *testing.T) {
+	for _, c := range []struct {
+		label, content, expected string
+	}{
+		{
+			label:    "http",
+			content:  `<a href="http://example.com/foo">`,
+			expected: `<a href="//example.com/foo">`,
+		},
+		{
+			label:    "https",
+			content:  `<a href="https://example.com/foo">`,
+			expected: `<a href="//example.com/foo">`,
+		},
+		{
+			label:    "scheme-relative",
+			content:  `<img src="//example.com/foo.png">`,
+			expected: `<img src="//example.com/foo.png">`,
+		},
+		{
+			label:    "case-insensitive",
+			content:  `<a href="HTTPS://Example.COM">`,
+			expected: `<a href="//example.com">`,
+		},
+		{
+			label:    "json",
+			content:  `{"url":"https:\/\/example.com\/foo"}`,
+			expected: `{"url":"//example.com\/foo"}`,
+		},
+		{
+			label:    "other-host",
+			content:  `<a href="https://example.org/">`,
+			expected: `<a href="https://example.org/">`,
+		},
+		{
+			label:    "prefix",
+			content:  `<a href="https://example.community/">`,
+			expected: `<a href="https://example.community/">`,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			actual := string(normalizeSelfLinks([]byte(c.content), "example.com"))
+			if actual != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCompareNormalizeSelfLinks(t *testing.T) {
+	oldContent := []byte(`<a href="http://example.com/">home</a><a href="http://example.com/about">about</a>`)
+	newContent := []byte(`<a href="https://example.com/">home</a><a href="https://example.com/about">about</a>`)
+	scores, err := compare(
+		context.Background(),
+		oldContent,
+		newContent,
+		nil,
+		"example.com",
+		Options{
+			Threshold:          1,
+			NormalizeSelfLinks: true,
+		},
+	)
+	if err != nil {
+		t.Fatalf("compare returned error: %v", err)
+	}
+	if scores.Similarity != 1 {
+		t.Errorf("Expected similarity to be 1, got %+v", scores)
+	}
+}
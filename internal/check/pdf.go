@@ -0,0 +1,214 @@
+package check
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"regexp"
+)
+
+var (
+	pdfStream    = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	pdfEndStream = []byte("endstream")
+	pdfFilter    = []byte("/Filter")
+	pdfFlate     = []byte("/FlateDecode")
+)
+
+// extractPDFText extracts the texts shown by the content streams in the PDF
+// document, separated by spaces.
+//
+// It's a best effort extraction for comparing, not for displaying:
+// Only FlateDecode and uncompressed streams are read,
+// and the strings are not mapped through the font encodings.
+// Truncated documents and streams are read as far as possible.
+//
+// As compressed streams can inflate by orders of magnitude,
+// the streams are only decompressed up to limit bytes in total,
+// and the returned text is capped to limit bytes as well.
+func extractPDFText(content []byte, limit int64) []byte {
+	var text bytes.Buffer
+	budget := limit
+	for _, m := range pdfStream.FindAllSubmatchIndex(content, -1) {
+		if budget <= 0 || int64(text.Len()) >= limit {
+			break
+		}
+		dict := content[m[2]:m[3]]
+		data := content[m[1]:]
+		if end := bytes.Index(data, pdfEndStream); end >= 0 {
+			data = data[:end]
+		}
+		if bytes.Contains(dict, pdfFlate) {
+			data = inflate(data, budget)
+			budget -= int64(len(data))
+		} else if bytes.Contains(dict, pdfFilter) {
+			// Other filters (images etc.) are not supported.
+			continue
+		}
+		pdfShownStrings(data, &text)
+	}
+	if int64(text.Len()) > limit {
+		text.Truncate(int(limit))
+	}
+	// Normalize the whitespaces like extractText does.
+	return bytes.Join(bytes.Fields(text.Bytes()), []byte(" "))
+}
+
+// inflate decompresses zlib data as far as possible,
+// up to limit bytes.
+func inflate(data []byte, limit int64) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	// Errors are expected on truncated streams,
+	// the data decompressed so far is still useful.
+	out, _ := io.ReadAll(io.LimitReader(r, limit))
+	return out
+}
+
+// pdfShownStrings writes the strings inside text objects (BT ... ET) of a
+// content stream into w, separated by spaces.
+func pdfShownStrings(stream []byte, w *bytes.Buffer) {
+	var inText bool
+	for i := 0; i < len(stream); {
+		switch c := stream[i]; {
+		case c == '(':
+			s, n := pdfLiteralString(stream[i:])
+			if inText {
+				w.Write(s)
+			}
+			i += n
+		case c == '<' && i+1 < len(stream) && stream[i+1] == '<':
+			// Dictionary, the keys and values are parsed as usual.
+			i += 2
+		case c == '<':
+			s, n := pdfHexString(stream[i:])
+			if inText {
+				w.Write(s)
+			}
+			i += n
+		case c == '%':
+			// Comment till the end of line.
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case isPDFRegular(c):
+			start := i
+			for i < len(stream) && isPDFRegular(stream[i]) {
+				i++
+			}
+			switch string(stream[start:i]) {
+			case "BT":
+				inText = true
+			case "ET":
+				inText = false
+				w.WriteByte(' ')
+			case "Tj", "TJ", "'", "\"", "T*", "Td", "TD":
+				if inText {
+					w.WriteByte(' ')
+				}
+			}
+		default:
+			i++
+		}
+	}
+}
+
+func isPDFRegular(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0,
+		'(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return false
+	}
+	return true
+}
+
+// pdfLiteralString parses the literal string at the beginning of b,
+// returning the string and the number of bytes consumed.
+func pdfLiteralString(b []byte) ([]byte, int) {
+	var s []byte
+	depth := 0
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; c {
+		case '(':
+			depth++
+			if depth > 1 {
+				s = append(s, c)
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, i + 1
+			}
+			s = append(s, c)
+		case '\\':
+			i++
+			if i >= len(b) {
+				return s, i
+			}
+			switch e := b[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b':
+				s = append(s, '\b')
+			case 'f':
+				s = append(s, '\f')
+			case '\r', '\n':
+				// Line continuation.
+			default:
+				if e >= '0' && e <= '7' {
+					v := 0
+					j := 0
+					for ; j < 3 && i+j < len(b) && b[i+j] >= '0' && b[i+j] <= '7'; j++ {
+						v = v*8 + int(b[i+j]-'0')
+					}
+					s = append(s, byte(v))
+					i += j - 1
+				} else {
+					s = append(s, e)
+				}
+			}
+		default:
+			s = append(s, c)
+		}
+	}
+	return s, len(b)
+}
+
+// pdfHexString parses the hex string at the beginning of b,
+// returning the string and the number of bytes consumed.
+func pdfHexString(b []byte) ([]byte, int) {
+	var s []byte
+	var hi byte
+	var odd bool
+	for i := 1; i < len(b); i++ {
+		c := b[i]
+		var v byte
+		switch {
+		case c == '>':
+			if odd {
+				s = append(s, hi<<4)
+			}
+			return s, i + 1
+		case c >= '0' && c <= '9':
+			v = c - '0'
+		case c >= 'a' && c <= 'f':
+			v = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			v = c - 'A' + 10
+		default:
+			continue
+		}
+		if odd {
+			s = append(s, hi<<4|v)
+		} else {
+			hi = v
+		}
+		odd = !odd
+	}
+	return s, len(b)
+}
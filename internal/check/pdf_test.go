@@ -0,0 +1,108 @@
+package check
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// newTestPDF creates a minimal PDF document showing texts,
+// with the content stream compressed when flate is true.
+func newTestPDF(flate bool, texts ...string) []byte {
+	var stream bytes.Buffer
+	for _, text := range texts {
+		fmt.Fprintf(&stream, "BT /F1 12 Tf 72 712 Td (%s) Tj ET\n", text)
+	}
+	data := stream.Bytes()
+	filter := ""
+	if flate {
+		var buf bytes.Buffer
+		w := zlib.NewWriter(&buf)
+		w.Write(data)
+		w.Close()
+		data = buf.Bytes()
+		filter = " /Filter /FlateDecode"
+	}
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	pdf.WriteString("1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n")
+	fmt.Fprintf(&pdf, "4 0 obj << /Length %d%s >>\nstream\n", len(data), filter)
+	pdf.Write(data)
+	pdf.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestExtractPDFText(t *testing.T) {
+	for _, c := range []struct {
+		label    string
+		content  []byte
+		expected string
+	}{
+		{
+			label:    "plain",
+			content:  newTestPDF(false, "Hello,", "world!"),
+			expected: "Hello, world!",
+		},
+		{
+			label:    "flate",
+			content:  newTestPDF(true, "Hello,", "world!"),
+			expected: "Hello, world!",
+		},
+		{
+			label: "operators",
+			content: []byte("<< /Length 99 >>\nstream\n" +
+				"% comment (not shown)\n" +
+				"(outside) Tj\n" +
+				"BT [(Hel) -20 (lo)] TJ <576f726c64> Tj (\\(nested \\050\\051\\)) Tj ET\n" +
+				"endstream"),
+			expected: "Hello World (nested ())",
+		},
+		{
+			label:    "truncated",
+			content:  newTestPDF(true, "Hello, world!")[:60],
+			expected: "",
+		},
+		{
+			label:    "not-pdf",
+			content:  []byte("<p>Hello, world!</p>"),
+			expected: "",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			if actual := string(extractPDFText(c.content, DefaultReadLimit)); actual != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExtractPDFTextLimit(t *testing.T) {
+	const (
+		limit = 1024
+		// Compresses by about three orders of magnitude.
+		inflated = 10 << 20
+	)
+	var stream bytes.Buffer
+	w := zlib.NewWriter(&stream)
+	line := []byte("BT (aaaaaaaa) Tj ET\n")
+	for written := 0; written < inflated; written += len(line) {
+		w.Write(line)
+	}
+	w.Close()
+
+	var pdf bytes.Buffer
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(&pdf, "%d 0 obj << /Length %d /Filter /FlateDecode >>\nstream\n", i, stream.Len())
+		pdf.Write(stream.Bytes())
+		pdf.WriteString("\nendstream\nendobj\n")
+	}
+
+	if out := inflate(stream.Bytes(), limit); len(out) != limit {
+		t.Errorf("Expected inflate to stop at %d bytes, got %d", limit, len(out))
+	}
+	text := extractPDFText(pdf.Bytes(), limit)
+	if len(text) == 0 || len(text) > limit {
+		t.Errorf("Expected up to %d bytes of text, got %d", limit, len(text))
+	}
+}
@@ -0,0 +1,83 @@
+package check
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// httpsCandidates returns the https urls to try for http url u, in order.
+//
+// The port of u is never carried over,
+// as a port serving plain http won't serve TLS at the same time.
+// The first candidate is always on the default https port (443),
+// followed by the same url on each of altPorts.
+func httpsCandidates(u *url.URL, altPorts []string) []*url.URL {
+	candidates := make([]*url.URL, 0, len(altPorts)+1)
+	httpsU := *u
+	httpsU.Scheme = "https"
+	httpsU.Host = hostWithPort(u.Hostname(), "")
+	candidates = append(candidates, &httpsU)
+	for _, port := range altPorts {
+		if port == "" || port == "443" {
+			continue
+		}
+		alt := httpsU
+		alt.Host = hostWithPort(u.Hostname(), port)
+		candidates = append(candidates, &alt)
+	}
+	return candidates
+}
+
+// hostWithPort joins host and port into the host part of a url,
+// omitting the port when it's empty.
+func hostWithPort(host, port string) string {
+	if port == "" {
+		if strings.Contains(host, ":") {
+			// IPv6 literal.
+			return "[" + host + "]"
+		}
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// dropDefaultPort returns u without the port when it's the default one of its
+// scheme.
+func dropDefaultPort(u *url.URL) *url.URL {
+	port := u.Port()
+	if (u.Scheme == "https" && port == "443") || (u.Scheme == "http" && port == "80") {
+		clone := *u
+		clone.Host = hostWithPort(u.Hostname(), "")
+		return &clone
+	}
+	return u
+}
+
+// fetchHTTPS fetches the https candidates of http url u in order,
+// until one of them succeeds.
+//
+// The returned Fetch is the one of the successful candidate,
+// or the one of the first candidate when none of them succeeded,
+// in which case the error of the first candidate is returned.
+func (c *Checker) fetchHTTPS(ctx context.Context, u *url.URL) (*Fetch, *response, error) {
+	var (
+		firstFetch *Fetch
+		firstResp  *response
+		firstErr   error
+	)
+	for _, candidate := range httpsCandidates(u, c.AltTLSPorts) {
+		f, resp, err := c.fetchResponse(ctx, candidate)
+		if err == nil {
+			return f, resp, nil
+		}
+		if firstFetch == nil {
+			firstFetch, firstResp, firstErr = f, resp, err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return firstFetch, firstResp, firstErr
+}
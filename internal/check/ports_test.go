@@ -0,0 +1,131 @@
+package check
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPSCandidates(t *testing.T) {
+	for _, c := range []struct {
+		url      string
+		altPorts []string
+		expected []string
+	}{
+		{
+			url:      "http://example.com/foo",
+			expected: []string{"https://example.com/foo"},
+		},
+		{
+			url:      "http://example.com:80/foo",
+			expected: []string{"https://example.com/foo"},
+		},
+		{
+			url:      "http://example.com:8080/foo?bar",
+			altPorts: []string{"8443", "443", ""},
+			expected: []string{"https://example.com/foo?bar", "https://example.com:8443/foo?bar"},
+		},
+		{
+			url:      "http://[::1]:8080/",
+			altPorts: []string{"8443"},
+			expected: []string{"https://[::1]/", "https://[::1]:8443/"},
+		},
+	} {
+		t.Run(c.url, func(t *testing.T) {
+			u, err := url.Parse(c.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual []string
+			for _, candidate := range httpsCandidates(u, c.altPorts) {
+				actual = append(actual, candidate.String())
+			}
+			if !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckPorts(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+
+	for _, c := range []struct {
+		label        string
+		url          string
+		altPorts     []string
+		httpsHandler http.Handler
+		httpsURL     string
+		fail         bool
+	}{
+		{
+			label:        "default-http-port",
+			url:          "http://" + testHost + ":80/foo",
+			httpsHandler: staticHandler(content),
+			httpsURL:     "https://" + testHost + "/foo",
+		},
+		{
+			label:        "non-default-http-port",
+			url:          "http://" + testHost + ":8080/foo",
+			httpsHandler: staticHandler(content),
+			httpsURL:     "https://" + testHost + "/foo",
+		},
+		{
+			label:    "alt-tls-port",
+			url:      "http://" + testHost + ":8080/foo",
+			altPorts: []string{"8443"},
+			httpsHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Host == testHost {
+					http.Error(w, "not here", http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(content))
+			}),
+			httpsURL: "https://" + testHost + ":8443/foo",
+		},
+		{
+			label: "no-alt-tls-port",
+			url:   "http://" + testHost + ":8080/foo",
+			httpsHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Host == testHost {
+					http.Error(w, "not here", http.StatusNotFound)
+					return
+				}
+				w.Write([]byte(content))
+			}),
+			fail: true,
+		},
+		{
+			label: "redirect-to-default-port",
+			url:   "http://" + testHost + "/foo",
+			httpsHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/foo" {
+					http.Redirect(w, r, "https://"+testHost+":443/bar", http.StatusFound)
+					return
+				}
+				w.Write([]byte(content))
+			}),
+			httpsURL: "https://" + testHost + "/bar",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			checker := newTestChecker(t, staticHandler(content), c.httpsHandler)
+			checker.AltTLSPorts = c.altPorts
+			result, err := checker.Check(context.Background(), c.url)
+			if c.fail {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check returned error: %v", err)
+			}
+			if result.HTTPSURL != c.httpsURL {
+				t.Errorf("Expected https url %q, got %q", c.httpsURL, result.HTTPSURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package check
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// redirectOutcome decides the outcome of a check from the redirect chains of
+// both sides.
+//
+// It also returns the final https url after redirects.
+//
+// It returns ErrDowngrades or ErrHostMismatch when the https url shouldn't be
+// recommended.
+func redirectOutcome(httpFetch, httpsFetch *Fetch) (Outcome, *url.URL, error) {
+	httpFinal, err := url.Parse(httpFetch.FinalURL())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse url %q: %w", httpFetch.FinalURL(), err)
+	}
+	httpsFinal, err := url.Parse(httpsFetch.FinalURL())
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse url %q: %w", httpsFetch.FinalURL(), err)
+	}
+
+	if httpsFinal.Scheme != "https" {
+		return OutcomeDowngrades, httpsFinal, fmt.Errorf(
+			"%q redirected to %q: %w",
+			httpsFetch.URL,
+			httpsFinal,
+			ErrDowngrades,
+		)
+	}
+	if !sameSite(httpFinal.Hostname(), httpsFinal.Hostname()) {
+		return OutcomeHostMismatch, httpsFinal, fmt.Errorf(
+			"%q ended up on %q while %q ended up on %q: %w",
+			httpFetch.URL,
+			httpFinal,
+			httpsFetch.URL,
+			httpsFinal,
+			ErrHostMismatch,
+		)
+	}
+	if httpFinal.Scheme == "https" {
+		return OutcomeAlreadyUpgrades, httpsFinal, nil
+	}
+	return OutcomeUpgradable, httpsFinal, nil
+}
+
+// sameSite reports whether hosts a and b are the same site,
+// which means they are the same host other than the "www." prefix.
+func sameSite(a, b string) bool {
+	const www = "www."
+	a = strings.TrimPrefix(strings.ToLower(a), www)
+	b = strings.TrimPrefix(strings.ToLower(b), www)
+	return a == b
+}
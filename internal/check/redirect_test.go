@@ -0,0 +1,139 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func redirectHandler(target string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	})
+}
+
+func TestCheckRedirects(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+
+	for _, c := range []struct {
+		label        string
+		path         string
+		httpHandler  http.Handler
+		httpsHandler http.Handler
+		outcome      Outcome
+		httpsURL     string
+		err          error
+	}{
+		{
+			label:        "upgradable",
+			path:         "/foo",
+			httpHandler:  staticHandler(content),
+			httpsHandler: staticHandler(content),
+			outcome:      OutcomeUpgradable,
+			httpsURL:     "https://" + testHost + "/foo",
+		},
+		{
+			label: "already-upgrades",
+			path:  "/foo",
+			httpHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "https://"+testHost+r.URL.Path, http.StatusMovedPermanently)
+			}),
+			httpsHandler: staticHandler(content),
+			outcome:      OutcomeAlreadyUpgrades,
+			httpsURL:     "https://" + testHost + "/foo",
+		},
+		{
+			label: "downgrades",
+			path:  "/foo",
+			httpHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(content))
+			}),
+			httpsHandler: redirectHandler("http://" + testHost + "/bar"),
+			outcome:      OutcomeDowngrades,
+			err:          ErrDowngrades,
+		},
+		{
+			label:       "host-mismatch",
+			path:        "/foo",
+			httpHandler: staticHandler(content),
+			httpsHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Host == testHost {
+					http.Redirect(w, r, "https://other."+testHost+r.URL.Path, http.StatusMovedPermanently)
+					return
+				}
+				w.Write([]byte(content))
+			}),
+			outcome: OutcomeHostMismatch,
+			err:     ErrHostMismatch,
+		},
+		{
+			label:       "www",
+			path:        "/foo#bar",
+			httpHandler: staticHandler(content),
+			httpsHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Host == testHost {
+					http.Redirect(w, r, "https://www."+testHost+r.URL.Path, http.StatusMovedPermanently)
+					return
+				}
+				w.Write([]byte(content))
+			}),
+			outcome:  OutcomeUpgradable,
+			httpsURL: "https://www." + testHost + "/foo#bar",
+		},
+		{
+			label:       "trailing-slash",
+			path:        "/foo",
+			httpHandler: staticHandler(content),
+			httpsHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/foo" {
+					http.Redirect(w, r, "/foo/", http.StatusMovedPermanently)
+					return
+				}
+				w.Write([]byte(content))
+			}),
+			outcome:  OutcomeUpgradable,
+			httpsURL: "https://" + testHost + "/foo/",
+		},
+		{
+			label:        "tracking",
+			path:         "/foo?id=1&utm_source=hn&fbclid=abc",
+			httpHandler:  staticHandler(content),
+			httpsHandler: staticHandler(content),
+			outcome:      OutcomeUpgradable,
+			httpsURL:     "https://" + testHost + "/foo?id=1",
+		},
+		{
+			label:       "tracking-required",
+			path:        "/foo?utm_source=hn",
+			httpHandler: staticHandler(content),
+			httpsHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.RawQuery == "" {
+					w.Write([]byte("<p>Something completely different.</p>"))
+					return
+				}
+				w.Write([]byte(content))
+			}),
+			outcome:  OutcomeUpgradable,
+			httpsURL: "https://" + testHost + "/foo?utm_source=hn",
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			checker := newTestChecker(t, c.httpHandler, c.httpsHandler)
+			checker.Threshold = 0.95
+			result, err := checker.Check(context.Background(), "http://"+testHost+c.path)
+			if c.err == nil && err != nil {
+				t.Fatalf("Check returned error: %v", err)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Errorf("Expected %v, got %v", c.err, err)
+			}
+			if result.Outcome != c.outcome {
+				t.Errorf("Expected outcome %q, got %q", c.outcome, result.Outcome)
+			}
+			if result.HTTPSURL != c.httpsURL {
+				t.Errorf("Expected https url %q, got %q", c.httpsURL, result.HTTPSURL)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package check
+
+import (
+	"net/http"
+	"time"
+)
+
+// Result is the result of Check.
+//
+// It's JSON serializable, so it can be logged or stored to explain the
+// decisions made.
+type Result struct {
+	// The http url being checked.
+	URL string `json:"url"`
+
+	// The https url with similar content,
+	// only set when the check succeeded.
+	//
+	// It's the final https url after redirects,
+	// with the tracking parameters stripped when that still leads to similar
+	// content.
+	HTTPSURL string `json:"https_url,omitempty"`
+
+	// The host variant the https url was derived from,
+	// empty when it's the host of the http url.
+	Variant HostVariant `json:"variant,omitempty"`
+
+	// The details of fetching the http url.
+	HTTP *Fetch `json:"http,omitempty"`
+
+	// The details of the second fetch of the http url,
+	// only set when Options.Calibrate is true.
+	Baseline *Fetch `json:"baseline,omitempty"`
+
+	// The details of fetching the https url.
+	HTTPS *Fetch `json:"https,omitempty"`
+
+	// The details of fetching the final https url with the tracking parameters
+	// stripped,
+	// only set when there were tracking parameters to strip.
+	Canonical *Fetch `json:"canonical,omitempty"`
+
+	// The details of the TLS connection of the https url,
+	// only set when the https url was fetched over TLS.
+	TLS *TLS `json:"tls,omitempty"`
+
+	// The mixed content of the https page,
+	// only set when the https url ended up on https.
+	MixedContent *MixedContent `json:"mixed_content,omitempty"`
+
+	// The HSTS status of the urls,
+	// only set when the https url was fetched.
+	HSTS *HSTS `json:"hsts,omitempty"`
+
+	// The similarity scores between the contents.
+	Scores Scores `json:"scores"`
+
+	// The outcome of the check,
+	// only set when the check went far enough to decide one.
+	Outcome Outcome `json:"outcome,omitempty"`
+
+	// Whether the result and error were served from Checker.Cache,
+	// in which case the other fields are those of the check cached.
+	Cached bool `json:"cached,omitempty"`
+
+	// The time the whole check took.
+	//
+	// As both sides are fetched concurrently,
+	// it's closer to the slower side's Fetch.Took than their sum.
+	Took time.Duration `json:"took"`
+}
+
+// Outcome is the kind of the decision Check made on an http url.
+type Outcome string
+
+// Outcomes of Check.
+const (
+	// The https url has similar content and can be recommended.
+	OutcomeUpgradable Outcome = "upgradable"
+
+	// The http url already redirects to its https version,
+	// which has similar content and can still be recommended.
+	OutcomeAlreadyUpgrades Outcome = "already_upgrades"
+
+	// The https url redirects back to http,
+	// Check returns ErrDowngrades with it.
+	OutcomeDowngrades Outcome = "downgrades"
+
+	// The http and https urls end up on different hosts after redirects
+	// (other than the "www." prefix),
+	// Check returns ErrHostMismatch with it.
+	OutcomeHostMismatch Outcome = "host_mismatch"
+
+	// Either url is disallowed by its robots.txt,
+	// Check returns ErrDisallowedByRobots with it.
+	OutcomeSkippedByRobots Outcome = "skipped_by_robots"
+)
+
+// Fetch is the details of fetching a url.
+type Fetch struct {
+	// The url being fetched.
+	URL string `json:"url"`
+
+	// Every url requested after the first one because of redirects,
+	// in order.
+	// The last one is the url the response is actually from.
+	Redirects []string `json:"redirects,omitempty"`
+
+	// The status code and content type of the final response.
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+
+	// The content length reported by the final response,
+	// -1 means unknown.
+	ContentLength int64 `json:"content_length"`
+
+	// The content encoding undone and the charset detected for the response
+	// body,
+	// empty when there's none.
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	Charset         string `json:"charset,omitempty"`
+
+	// The number of bytes actually read from the response body after
+	// decoding into utf-8,
+	// capped by the read limit.
+	BytesRead int `json:"bytes_read"`
+
+	// The time it took to receive the response headers (including redirects),
+	// and the time it took to finish reading the response body.
+	TimeToHeaders time.Duration `json:"time_to_headers"`
+	Took          time.Duration `json:"took"`
+}
+
+// FinalURL returns the url the response is actually from,
+// after following all the redirects.
+func (f *Fetch) FinalURL() string {
+	if len(f.Redirects) > 0 {
+		return f.Redirects[len(f.Redirects)-1]
+	}
+	return f.URL
+}
+
+// redirects returns the redirect chain of resp.
+func redirects(resp *http.Response) []string {
+	var chain []string
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		chain = append(chain, req.URL.String())
+	}
+	// Reverse the chain, as we walked it backwards.
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
@@ -0,0 +1,91 @@
+package check
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/c", http.StatusFound)
+	})
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("content-type", "text/html; charset=utf-8")
+		w.Write([]byte("<p>Hello, world!</p>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	u, err := url.Parse(server.URL + "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, content, err := (&Checker{Client: testClient, ReadLimit: 5}).fetch(context.Background(), u)
+	if err != nil {
+		t.Fatalf("fetch returned error: %v", err)
+	}
+	if string(content) != "<p>He" {
+		t.Errorf("Expected content to be limited to %q, got %q", "<p>He", content)
+	}
+	expected := []string{server.URL + "/b", server.URL + "/c"}
+	if !reflect.DeepEqual(f.Redirects, expected) {
+		t.Errorf("Expected redirects %q, got %q", expected, f.Redirects)
+	}
+	if f.FinalURL() != server.URL+"/c" {
+		t.Errorf("Expected final url %q, got %q", server.URL+"/c", f.FinalURL())
+	}
+	if f.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, f.StatusCode)
+	}
+	if f.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("Unexpected content type %q", f.ContentType)
+	}
+	if f.BytesRead != 5 {
+		t.Errorf("Expected 5 bytes read, got %d", f.BytesRead)
+	}
+	if f.Took < f.TimeToHeaders || f.TimeToHeaders <= 0 {
+		t.Errorf("Unexpected timings: %v, %v", f.TimeToHeaders, f.Took)
+	}
+
+	result := &Result{
+		URL:  u.String(),
+		HTTP: f,
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+	var decoded Result
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal result: %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, result) {
+		t.Errorf("Expected %+v, got %+v", result, &decoded)
+	}
+}
+
+func TestFetchStatus(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := (&Checker{Client: testClient, ReadLimit: 1024}).fetch(context.Background(), u)
+	if err == nil {
+		t.Error("Expected error on 404, got nil")
+	}
+	if f.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d", http.StatusNotFound, f.StatusCode)
+	}
+}
@@ -0,0 +1,440 @@
+package check
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/reddit/baseplate.go/httpbp"
+)
+
+// Default values used by Robots when the corresponding fields are not
+// positive.
+const (
+	DefaultRobotsTTL         = 24 * time.Hour
+	DefaultRobotsNegativeTTL = 30 * time.Minute
+	DefaultRobotsMaxEntries  = 10000
+)
+
+// robotsReadLimit is the max size of robots.txt files parsed,
+// the rest is ignored (RFC 9309 section 2.5).
+const robotsReadLimit = 500 << 10
+
+// Robots fetches, parses and caches the robots.txt files of the urls Checker
+// fetches,
+// and holds the requests back to the paths they disallow and to the
+// crawl-delays they ask for.
+//
+// The robots.txt files are per scheme, host and port,
+// so the http and https urls are checked against their own files.
+// When a robots.txt file can't be fetched,
+// 4xx statuses allow everything and 5xx statuses disallow everything until
+// NegativeTTL expires,
+// while the other failures allow the request to fail on its own.
+//
+// A Robots is safe for concurrent use,
+// as long as its fields are not modified after the first use.
+type Robots struct {
+	// The time to keep the fetched robots.txt files.
+	//
+	// When it's not positive, DefaultRobotsTTL is used.
+	TTL time.Duration
+
+	// The time to keep the robots.txt files failed with 5xx statuses,
+	// which are usually temporary.
+	//
+	// When it's not positive, DefaultRobotsNegativeTTL is used.
+	NegativeTTL time.Duration
+
+	// The max number of robots.txt files to keep.
+	//
+	// When it's not positive, DefaultRobotsMaxEntries is used.
+	MaxEntries int
+
+	mu      sync.Mutex
+	origins map[string]*robotsEntry
+}
+
+// robotsEntry is the cached robots.txt of an origin.
+type robotsEntry struct {
+	// Closed after rules and expires are set.
+	ready chan struct{}
+
+	// nil allows everything.
+	rules   *robotsRules
+	expires time.Time
+
+	// The earliest time of the next request under crawl-delay,
+	// guarded by Robots.mu.
+	next time.Time
+}
+
+// robotsRule is an allow or disallow rule of robots.txt.
+type robotsRule struct {
+	allow   bool
+	pattern *regexp.Regexp
+
+	// The length of the path pattern, longer ones take precedence.
+	length int
+}
+
+// robotsRules is the rules of robots.txt that apply to a user agent.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// disallowAll is the rules used when robots.txt is unreachable.
+var disallowAll = &robotsRules{
+	rules: []robotsRule{{
+		pattern: regexp.MustCompile(`^/`),
+		length:  1,
+	}},
+}
+
+// robotsAgent returns the product token of userAgent,
+// which is what robots.txt groups are matched against.
+func robotsAgent(userAgent string) string {
+	if userAgent == "" {
+		// The default user agent of net/http.
+		return "go-http-client"
+	}
+	token := strings.FieldsFunc(userAgent, func(r rune) bool {
+		return r == '/' || r == ' '
+	})
+	if len(token) == 0 {
+		return ""
+	}
+	return strings.ToLower(token[0])
+}
+
+// parseRobots parses robots.txt from r,
+// returning the rules of the groups matching agent,
+// or the rules of the "*" groups when none of them matches.
+func parseRobots(r io.Reader, agent string) (*robotsRules, error) {
+	var (
+		matched, wildcard     robotsRules
+		anyMatched            bool
+		inAgents              bool
+		toMatched, toWildcard bool
+	)
+	scanner := bufio.NewScanner(io.LimitReader(r, robotsReadLimit))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+
+		if key == "user-agent" {
+			if !inAgents {
+				// A new group.
+				toMatched, toWildcard = false, false
+				inAgents = true
+			}
+			switch strings.ToLower(value) {
+			case agent:
+				toMatched = true
+				anyMatched = true
+			case "*":
+				toWildcard = true
+			}
+			continue
+		}
+		inAgents = false
+
+		var apply func(*robotsRules)
+		switch key {
+		case "allow", "disallow":
+			if value == "" {
+				// An empty disallow allows everything, which is the default.
+				continue
+			}
+			rule := robotsRule{
+				allow:   key == "allow",
+				pattern: robotsPattern(value),
+				length:  len(value),
+			}
+			apply = func(rules *robotsRules) {
+				rules.rules = append(rules.rules, rule)
+			}
+		case "crawl-delay":
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			delay := time.Duration(seconds * float64(time.Second))
+			apply = func(rules *robotsRules) {
+				rules.crawlDelay = delay
+			}
+		default:
+			continue
+		}
+		if toMatched {
+			apply(&matched)
+		}
+		if toWildcard {
+			apply(&wildcard)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if anyMatched {
+		return &matched, nil
+	}
+	return &wildcard, nil
+}
+
+// robotsPattern compiles the path pattern of an allow or disallow rule,
+// in which "*" matches any sequence of characters and a trailing "$" matches
+// the end of the path.
+func robotsPattern(path string) *regexp.Regexp {
+	var anchored bool
+	if strings.HasSuffix(path, "$") {
+		path = path[:len(path)-1]
+		anchored = true
+	}
+	parts := strings.Split(path, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed reports whether the path (with the query) is allowed by the rules.
+//
+// The longest matching rule wins, and allow wins the ties.
+func (r *robotsRules) allowed(path string) bool {
+	if r == nil {
+		return true
+	}
+	var (
+		allow   = true
+		longest = -1
+	)
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || (rule.length == longest && rule.allow) {
+			allow = rule.allow
+			longest = rule.length
+		}
+	}
+	return allow
+}
+
+func (r *Robots) ttl() time.Duration {
+	if r.TTL > 0 {
+		return r.TTL
+	}
+	return DefaultRobotsTTL
+}
+
+func (r *Robots) negativeTTL() time.Duration {
+	if r.NegativeTTL > 0 {
+		return r.NegativeTTL
+	}
+	return DefaultRobotsNegativeTTL
+}
+
+func (r *Robots) maxEntries() int {
+	if r.MaxEntries > 0 {
+		return r.MaxEntries
+	}
+	return DefaultRobotsMaxEntries
+}
+
+// entry returns the robots.txt of the origin of u,
+// fetching it with client when it's not cached.
+func (r *Robots) entry(ctx context.Context, client *http.Client, u *url.URL, userAgent string) (*robotsEntry, error) {
+	origin := strings.ToLower(u.Scheme + "://" + u.Host)
+	now := time.Now()
+
+	r.mu.Lock()
+	if r.origins == nil {
+		r.origins = make(map[string]*robotsEntry)
+	}
+	e := r.origins[origin]
+	if e != nil {
+		select {
+		case <-e.ready:
+			if !now.Before(e.expires) {
+				e = nil
+			}
+		default:
+			// Being fetched by another request.
+		}
+	}
+	if e != nil {
+		r.mu.Unlock()
+		select {
+		case <-e.ready:
+			return e, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	e = &robotsEntry{
+		ready: make(chan struct{}),
+	}
+	r.evict(now)
+	r.origins[origin] = e
+	r.mu.Unlock()
+
+	defer close(e.ready)
+	rules, ttl := r.fetch(ctx, client, u, userAgent)
+	e.rules = rules
+	e.expires = now.Add(ttl)
+	return e, nil
+}
+
+// evict makes room for a new entry.
+//
+// It must be called with r.mu held.
+func (r *Robots) evict(now time.Time) {
+	if len(r.origins) < r.maxEntries() {
+		return
+	}
+	for origin, e := range r.origins {
+		select {
+		case <-e.ready:
+			if !now.Before(e.expires) {
+				delete(r.origins, origin)
+			}
+		default:
+		}
+	}
+	for origin := range r.origins {
+		if len(r.origins) < r.maxEntries() {
+			break
+		}
+		delete(r.origins, origin)
+	}
+}
+
+// fetch fetches and parses the robots.txt of the origin of u,
+// returning the time to cache the result for.
+//
+// ttl is 0 when the result shouldn't be cached.
+func (r *Robots) fetch(ctx context.Context, client *http.Client, u *url.URL, userAgent string) (rules *robotsRules, ttl time.Duration) {
+	robotsURL := url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   "/robots.txt",
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return nil, 0
+	}
+	if userAgent != "" {
+		req.Header.Set("user-agent", userAgent)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		// Let the request itself fail with the same error.
+		return nil, 0
+	}
+	defer httpbp.DrainAndClose(resp.Body)
+	switch {
+	case resp.StatusCode >= 500:
+		return disallowAll, r.negativeTTL()
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return nil, r.ttl()
+	}
+	rules, err = parseRobots(resp.Body, robotsAgent(userAgent))
+	if err != nil {
+		return nil, 0
+	}
+	return rules, r.ttl()
+}
+
+// robotsTransport is an http.RoundTripper holding the requests to base back
+// with robots.
+//
+// The requests that can't wait for the crawl-delay before the deadlines of
+// their contexts fail with a *RateLimitError.
+type robotsTransport struct {
+	base      http.RoundTripper
+	robots    *Robots
+	userAgent string
+}
+
+func (t *robotsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	client := &http.Client{
+		Transport: t.base,
+	}
+	e, err := t.robots.entry(ctx, client, req.URL, t.userAgent)
+	if err != nil {
+		return nil, err
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	if !e.rules.allowed(path) {
+		return nil, fmt.Errorf("%w: %q", ErrDisallowedByRobots, req.URL.String())
+	}
+
+	if e.rules != nil && e.rules.crawlDelay > 0 {
+		now := time.Now()
+		t.robots.mu.Lock()
+		wait := e.next.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		// Don't hold the following requests back for a request that can't
+		// be made before its deadline anyway.
+		if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+			t.robots.mu.Unlock()
+			return nil, &RateLimitError{Host: strings.ToLower(req.URL.Hostname())}
+		}
+		prev, next := e.next, now.Add(wait+e.rules.crawlDelay)
+		e.next = next
+		t.robots.mu.Unlock()
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				t.robots.mu.Lock()
+				// Give the reservation back,
+				// unless other requests have been queued after it.
+				if e.next.Equal(next) {
+					e.next = prev
+				}
+				t.robots.mu.Unlock()
+				return nil, ctx.Err()
+			}
+		}
+	}
+
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,300 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRobotsAgent(t *testing.T) {
+	for ua, expected := range map[string]string{
+		"httpsbot/1.0 (+https://github.com/fishy/https-bot)": "httpsbot",
+		"HTTPSBot":    "httpsbot",
+		"":            "go-http-client",
+		"Foo Bar/1.0": "foo",
+	} {
+		if got := robotsAgent(ua); got != expected {
+			t.Errorf("robotsAgent(%q) = %q, expected %q", ua, got, expected)
+		}
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	const robots = `# comment
+User-agent: *
+Disallow: /private
+Crawl-delay: 1
+
+User-agent: otherbot
+User-Agent: HTTPSBot # ours
+Disallow: /*.pdf$
+Disallow: /tmp/
+Allow: /tmp/public
+Crawl-delay: 0.5
+
+User-agent: otherbot
+Disallow: /
+`
+	for _, c := range []struct {
+		label    string
+		agent    string
+		allowed  []string
+		disallow []string
+		delay    time.Duration
+	}{
+		{
+			label:    "matched",
+			agent:    "httpsbot",
+			allowed:  []string{"/", "/private", "/tmp/public/foo", "/foo.pdf?download=1"},
+			disallow: []string{"/tmp/", "/tmp/foo", "/foo.pdf", "/a/b.pdf"},
+			delay:    500 * time.Millisecond,
+		},
+		{
+			label:    "merged",
+			agent:    "otherbot",
+			allowed:  []string{"/tmp/public"},
+			disallow: []string{"/", "/foo"},
+			delay:    500 * time.Millisecond,
+		},
+		{
+			label:    "wildcard",
+			agent:    "somebot",
+			allowed:  []string{"/", "/tmp/foo"},
+			disallow: []string{"/private", "/private/foo"},
+			delay:    time.Second,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			rules, err := parseRobots(strings.NewReader(robots), c.agent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, path := range c.allowed {
+				if !rules.allowed(path) {
+					t.Errorf("Expected %q allowed", path)
+				}
+			}
+			for _, path := range c.disallow {
+				if rules.allowed(path) {
+					t.Errorf("Expected %q disallowed", path)
+				}
+			}
+			if rules.crawlDelay != c.delay {
+				t.Errorf("crawlDelay = %v, expected %v", rules.crawlDelay, c.delay)
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		rules, err := parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"), "httpsbot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rules.allowed("/foo") {
+			t.Error("Expected empty disallow to allow everything")
+		}
+	})
+}
+
+// robotsHandler serves robots.txt with status and body,
+// and the other paths with handler.
+func robotsHandler(status int, body string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("content-type", "text/plain")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+}
+
+func TestRobotsTTL(t *testing.T) {
+	r := &Robots{
+		TTL:         time.Hour,
+		NegativeTTL: time.Minute,
+	}
+	for _, c := range []struct {
+		label    string
+		status   int
+		expected time.Duration
+	}{
+		{
+			label:    "ok",
+			status:   http.StatusOK,
+			expected: time.Hour,
+		},
+		{
+			label:    "not-found",
+			status:   http.StatusNotFound,
+			expected: time.Hour,
+		},
+		{
+			label:    "unreachable",
+			status:   http.StatusServiceUnavailable,
+			expected: time.Minute,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			server := httptest.NewServer(robotsHandler(c.status, "User-agent: *\nDisallow: /private\n", nil))
+			t.Cleanup(server.Close)
+			u, _ := url.Parse(server.URL)
+			start := time.Now()
+			e, err := r.entry(context.Background(), server.Client(), u, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ttl := e.expires.Sub(start); ttl < c.expected || ttl > c.expected+time.Second {
+				t.Errorf("Expected cached for %v, got %v", c.expected, ttl)
+			}
+		})
+	}
+}
+
+func TestCheckerRobots(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+
+	for _, c := range []struct {
+		label           string
+		httpsStatus     int
+		httpsRobots     string
+		path            string
+		expectedErr     error
+		expectedOutcome Outcome
+	}{
+		{
+			label:           "allowed",
+			httpsStatus:     http.StatusOK,
+			httpsRobots:     "User-agent: *\nDisallow: /private\n",
+			path:            "/public",
+			expectedOutcome: OutcomeUpgradable,
+		},
+		{
+			label:           "disallowed",
+			httpsStatus:     http.StatusOK,
+			httpsRobots:     "User-agent: *\nDisallow: /private\n",
+			path:            "/private",
+			expectedErr:     ErrDisallowedByRobots,
+			expectedOutcome: OutcomeSkippedByRobots,
+		},
+		{
+			label:           "not-found",
+			httpsStatus:     http.StatusNotFound,
+			path:            "/private",
+			expectedOutcome: OutcomeUpgradable,
+		},
+		{
+			label:           "unreachable",
+			httpsStatus:     http.StatusServiceUnavailable,
+			path:            "/public",
+			expectedErr:     ErrDisallowedByRobots,
+			expectedOutcome: OutcomeSkippedByRobots,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			checker := newTestChecker(
+				t,
+				robotsHandler(http.StatusNotFound, "", staticHandler(content)),
+				robotsHandler(c.httpsStatus, c.httpsRobots, staticHandler(content)),
+			)
+			checker.Robots = &Robots{}
+			result, err := checker.Check(context.Background(), "http://"+testHost+c.path)
+			if !errors.Is(err, c.expectedErr) {
+				t.Errorf("Expected error %v, got %v", c.expectedErr, err)
+			}
+			if result.Outcome != c.expectedOutcome {
+				t.Errorf("Expected outcome %q, got %q", c.expectedOutcome, result.Outcome)
+			}
+		})
+	}
+
+	t.Run("http", func(t *testing.T) {
+		checker := newTestChecker(
+			t,
+			robotsHandler(http.StatusOK, "User-agent: httpsbot\nDisallow: /\n", staticHandler(content)),
+			robotsHandler(http.StatusNotFound, "", staticHandler(content)),
+		)
+		checker.UserAgent = "httpsbot/1.0"
+		checker.Robots = &Robots{}
+		_, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		if !errors.Is(err, ErrDisallowedByRobots) {
+			t.Errorf("Expected %v, got %v", ErrDisallowedByRobots, err)
+		}
+	})
+
+	t.Run("crawl-delay", func(t *testing.T) {
+		const delay = 200 * time.Millisecond
+		robots := "User-agent: *\nCrawl-delay: 0.2\n"
+		checker := newTestChecker(
+			t,
+			robotsHandler(http.StatusOK, robots, staticHandler(content)),
+			robotsHandler(http.StatusOK, robots, staticHandler(content)),
+		)
+		checker.Robots = &Robots{}
+		if _, err := checker.Check(context.Background(), "http://"+testHost+"/"); err != nil {
+			t.Fatal(err)
+		}
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Slack for the time between the two checks.
+		if result.Took < delay/2 {
+			t.Errorf("Expected the second check delayed by about %v, took %v", delay, result.Took)
+		}
+	})
+
+	t.Run("crawl-delay-giveup", func(t *testing.T) {
+		const delay = 2 * time.Second
+		robots := "User-agent: *\nCrawl-delay: 2\n"
+		checker := newTestChecker(
+			t,
+			robotsHandler(http.StatusOK, robots, staticHandler(content)),
+			robotsHandler(http.StatusOK, robots, staticHandler(content)),
+		)
+		checker.Robots = &Robots{}
+		start := time.Now()
+		if _, err := checker.Check(context.Background(), "http://"+testHost+"/"); err != nil {
+			t.Fatal(err)
+		}
+		next := func() (latest time.Time) {
+			checker.Robots.mu.Lock()
+			defer checker.Robots.mu.Unlock()
+			for _, e := range checker.Robots.origins {
+				if e.next.After(latest) {
+					latest = e.next
+				}
+			}
+			return latest
+		}
+		expected := next()
+		if expected.Before(start.Add(delay)) {
+			t.Fatalf("Expected the next request held back by %v, got %v", delay, expected.Sub(start))
+		}
+
+		for i := 0; i < 3; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			_, err := checker.Check(ctx, "http://"+testHost+"/")
+			cancel()
+			if !errors.Is(err, ErrRateLimited) {
+				t.Errorf("#%d: Expected %v, got %v", i, ErrRateLimited, err)
+			}
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+		if _, err := checker.Check(ctx, "http://"+testHost+"/"); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected %v, got %v", context.Canceled, err)
+		}
+
+		if actual := next(); !actual.Equal(expected) {
+			t.Errorf("Expected the next request at +%v, got +%v", expected.Sub(start), actual.Sub(start))
+		}
+	})
+}
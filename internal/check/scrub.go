@@ -0,0 +1,151 @@
+package check
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// scrubMask is what the volatile tokens are replaced with.
+const scrubMask = "*"
+
+// A ScrubRule defines a kind of volatile tokens to be masked by Scrubber.
+type ScrubRule struct {
+	// Name of the rule, used in Mask.
+	Name string
+
+	// The regular expression matching the volatile tokens.
+	//
+	// If it has capturing groups, only the text matched by the first group is
+	// masked, otherwise the whole match is masked.
+	Pattern *regexp.Regexp
+}
+
+// Mask is a volatile token masked by Scrubber.
+type Mask struct {
+	// Name of the rule that masked the token.
+	Rule string
+
+	// The original token being masked.
+	Token string
+}
+
+// DefaultScrubRules are the built-in rules for volatile tokens commonly seen
+// in web pages.
+var DefaultScrubRules = []ScrubRule{
+	{
+		// <script nonce="..."> and Content-Security-Policy 'nonce-...'
+		Name:    "nonce",
+		Pattern: regexp.MustCompile(`(?i)\bnonce(?:=["']?|-)([A-Za-z0-9+/=_-]+)`),
+	},
+	{
+		// <input type="hidden" name="csrf_token" value="...">
+		Name:    "csrf-input",
+		Pattern: regexp.MustCompile(`(?i)<input[^>]+name=["']?[\w-]*(?:csrf|xsrf|token|authenticity)[\w-]*["']?[^>]+value=["']?([^"'\s>]*)`),
+	},
+	{
+		// <input type="hidden" value="..." name="csrf_token">
+		Name:    "csrf-input",
+		Pattern: regexp.MustCompile(`(?i)<input[^>]+value=["']?([^"'\s>]*)["']?[^>]+name=["']?[\w-]*(?:csrf|xsrf|token|authenticity)`),
+	},
+	{
+		// <meta name="csrf-token" content="...">
+		Name:    "csrf-meta",
+		Pattern: regexp.MustCompile(`(?i)<meta[^>]+name=["']?[\w-]*(?:csrf|xsrf)[\w-]*["']?[^>]+content=["']?([^"'\s>]*)`),
+	},
+	{
+		// /static/app.js?v=1a2b3c
+		Name:    "cache-buster",
+		Pattern: regexp.MustCompile(`[?&](?:v|ver|version|_|cb|t|ts|hash|rev)=([\w.-]+)`),
+	},
+	{
+		// "requestId": "...", data-request-id="..."
+		Name:    "request-id",
+		Pattern: regexp.MustCompile(`(?i)\b(?:request|trace|correlation)[_-]?id["']?\s*[:=]\s*["']?([\w-]+)`),
+	},
+	{
+		// 2021-04-01T12:34:56Z, 2021-04-01 12:34:56.789+08:00
+		Name:    "timestamp",
+		Pattern: regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`),
+	},
+	{
+		// 12:34:56
+		Name:    "time",
+		Pattern: regexp.MustCompile(`\b\d{1,2}:\d{2}:\d{2}\b`),
+	},
+}
+
+// Scrubber masks volatile tokens in contents before comparing,
+// so that they don't make two fetches of the same page dissimilar.
+type Scrubber struct {
+	Rules []ScrubRule
+
+	// When Debug is non-nil, it's called with every token being masked.
+	Debug func(mask Mask)
+}
+
+// NewScrubber creates a Scrubber with the user supplied regular expressions.
+//
+// If builtin is true, DefaultScrubRules are also included,
+// before the user supplied ones.
+// User supplied rules are named by their regular expressions.
+func NewScrubber(builtin bool, patterns ...string) (*Scrubber, error) {
+	var s Scrubber
+	if builtin {
+		s.Rules = append(s.Rules, DefaultScrubRules...)
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scrub pattern %q: %w", pattern, err)
+		}
+		s.Rules = append(s.Rules, ScrubRule{
+			Name:    pattern,
+			Pattern: re,
+		})
+	}
+	return &s, nil
+}
+
+// Scrub returns content with all the volatile tokens masked.
+//
+// The rules are applied in order,
+// so tokens masked by an earlier rule are no longer seen by later rules.
+func (s *Scrubber) Scrub(content []byte) []byte {
+	for _, rule := range s.Rules {
+		content = s.apply(rule, content)
+	}
+	return content
+}
+
+func (s *Scrubber) apply(rule ScrubRule, content []byte) []byte {
+	matches := rule.Pattern.FindAllSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content
+	}
+	var buf bytes.Buffer
+	buf.Grow(len(content))
+	var last int
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) >= 4 {
+			start, end = match[2], match[3]
+		}
+		if start < 0 || start == end {
+			// The capturing group didn't participate in the match,
+			// or matched nothing.
+			continue
+		}
+		if s.Debug != nil {
+			s.Debug(Mask{
+				Rule:  rule.Name,
+				Token: string(content[start:end]),
+			})
+		}
+		buf.Write(content[last:start])
+		buf.WriteString(scrubMask)
+		last = end
+	}
+	buf.Write(content[last:])
+	return buf.Bytes()
+}
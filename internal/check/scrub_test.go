@@ -0,0 +1,124 @@
+package check
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestScrubber(t *testing.T) {
+	for _, c := range []struct {
+		label, content, expected string
+		masks                    []Mask
+	}{
+		{
+			label:    "nonce",
+			content:  `<script nonce="r4nd0m+/=">`,
+			expected: `<script nonce="*">`,
+			masks:    []Mask{{Rule: "nonce", Token: "r4nd0m+/="}},
+		},
+		{
+			label:    "csrf-input",
+			content:  `<input type="hidden" name="csrf_token" value="abc123">`,
+			expected: `<input type="hidden" name="csrf_token" value="*">`,
+			masks:    []Mask{{Rule: "csrf-input", Token: "abc123"}},
+		},
+		{
+			label:    "csrf-input-value-first",
+			content:  `<input type="hidden" value="abc123" name="authenticity_token">`,
+			expected: `<input type="hidden" value="*" name="authenticity_token">`,
+			masks:    []Mask{{Rule: "csrf-input", Token: "abc123"}},
+		},
+		{
+			label:    "csrf-meta",
+			content:  `<meta name="csrf-token" content="abc123">`,
+			expected: `<meta name="csrf-token" content="*">`,
+			masks:    []Mask{{Rule: "csrf-meta", Token: "abc123"}},
+		},
+		{
+			label:    "cache-buster",
+			content:  `<link href="/app.css?v=1a2b3c"><script src="/app.js?foo=bar&_=1617235200"></script>`,
+			expected: `<link href="/app.css?v=*"><script src="/app.js?foo=bar&_=*"></script>`,
+			masks: []Mask{
+				{Rule: "cache-buster", Token: "1a2b3c"},
+				{Rule: "cache-buster", Token: "1617235200"},
+			},
+		},
+		{
+			label:    "request-id",
+			content:  `{"requestId": "f00-ba4"}`,
+			expected: `{"requestId": "*"}`,
+			masks:    []Mask{{Rule: "request-id", Token: "f00-ba4"}},
+		},
+		{
+			label:    "timestamp",
+			content:  `Generated at 2021-04-01T12:34:56.789Z.`,
+			expected: `Generated at *.`,
+			masks:    []Mask{{Rule: "timestamp", Token: "2021-04-01T12:34:56.789Z"}},
+		},
+		{
+			label:    "time",
+			content:  `Rendered at 12:34:56 in 0.1s`,
+			expected: `Rendered at * in 0.1s`,
+			masks:    []Mask{{Rule: "time", Token: "12:34:56"}},
+		},
+		{
+			label:    "nothing",
+			content:  `<p>Hello, world!</p>`,
+			expected: `<p>Hello, world!</p>`,
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			s, err := NewScrubber(true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var masks []Mask
+			s.Debug = func(m Mask) {
+				masks = append(masks, m)
+			}
+			actual := string(s.Scrub([]byte(c.content)))
+			if actual != c.expected {
+				t.Errorf("Expected %q, got %q", c.expected, actual)
+			}
+			if !reflect.DeepEqual(masks, c.masks) {
+				t.Errorf("Expected masks %+v, got %+v", c.masks, masks)
+			}
+		})
+	}
+}
+
+func TestScrubberUserPatterns(t *testing.T) {
+	if _, err := NewScrubber(false, `(`); err == nil {
+		t.Error("Expected error for invalid pattern, got nil")
+	}
+
+	s, err := NewScrubber(false, `visitor #(\d+)`, `\bsession-\w+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := []byte(`You are visitor #12345, session-abc, at 12:34:56`)
+	expected := `You are visitor #*, *, at 12:34:56`
+	if actual := string(s.Scrub(content)); actual != expected {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
+
+func TestCompareScrubber(t *testing.T) {
+	s, err := NewScrubber(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldContent := []byte(`<script nonce="abcdefgh" src="/app.js?v=12345678"></script><p>Hello at 2021-04-01 12:00:00</p>`)
+	newContent := []byte(`<script nonce="hgfedcba" src="/app.js?v=87654321"></script><p>Hello at 2021-04-01 12:00:01</p>`)
+	scores, err := compare(context.Background(), oldContent, newContent, nil, "", Options{
+		Threshold: 1,
+		Scrubber:  s,
+	})
+	if err != nil {
+		t.Fatalf("compare returned error: %v", err)
+	}
+	if scores.Similarity != 1 {
+		t.Errorf("Expected similarity to be 1, got %+v", scores)
+	}
+}
@@ -0,0 +1,153 @@
+package check
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// TLS is the details of the TLS connection of the https response.
+type TLS struct {
+	// The negotiated TLS version and cipher suite, e.g. "TLS 1.3" and
+	// "TLS_AES_128_GCM_SHA256".
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipher_suite"`
+
+	// The details of the leaf certificate.
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	SANs      []string  `json:"sans,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+
+	// The subjects of the verified chain, from the leaf to the root.
+	//
+	// It's empty when the chain was not verified by the client.
+	Chain []string `json:"chain,omitempty"`
+
+	// Whether the leaf certificate is signed by itself.
+	SelfSigned bool `json:"self_signed,omitempty"`
+}
+
+// TLSPolicy is the policy the TLS connection of the https url must meet for it
+// to be recommended.
+//
+// The zero value accepts everything the http client accepts.
+type TLSPolicy struct {
+	// The minimal TLS version, e.g. tls.VersionTLS12.
+	MinVersion uint16
+
+	// The minimal time the certificate must still be valid for.
+	MinValidity time.Duration
+
+	// When RejectSelfSigned is true, self-signed certificates are refused
+	// even if the http client trusts them.
+	RejectSelfSigned bool
+}
+
+// ParseTLSVersion parses a TLS version in the form of "1.2" or "TLS 1.2".
+//
+// Empty string parses to 0.
+func ParseTLSVersion(s string) (uint16, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "1.0", "TLS 1.0":
+		return tls.VersionTLS10, nil
+	case "1.1", "TLS 1.1":
+		return tls.VersionTLS11, nil
+	case "1.2", "TLS 1.2":
+		return tls.VersionTLS12, nil
+	case "1.3", "TLS 1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("unknown tls version %q", s)
+}
+
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// newTLS returns the TLS details from the connection state,
+// or nil if state is nil.
+func newTLS(state *tls.ConnectionState) *TLS {
+	if state == nil {
+		return nil
+	}
+	t := &TLS{
+		Version:     tlsVersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		t.Subject = leaf.Subject.String()
+		t.Issuer = leaf.Issuer.String()
+		t.SANs = sans(leaf)
+		t.NotBefore = leaf.NotBefore
+		t.NotAfter = leaf.NotAfter
+		// Not using CheckSignatureFrom as it also requires leaf to be a CA.
+		t.SelfSigned = bytes.Equal(leaf.RawIssuer, leaf.RawSubject) &&
+			leaf.CheckSignature(leaf.SignatureAlgorithm, leaf.RawTBSCertificate, leaf.Signature) == nil
+	}
+	if len(state.VerifiedChains) > 0 {
+		for _, cert := range state.VerifiedChains[0] {
+			t.Chain = append(t.Chain, cert.Subject.String())
+		}
+	}
+	return t
+}
+
+// sans returns all the subject alternative names of cert.
+func sans(cert *x509.Certificate) []string {
+	names := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	return names
+}
+
+// check checks t against the policy at time now.
+//
+// It returns an error matching ErrTLSPolicy when t violates the policy.
+func (p TLSPolicy) check(t *TLS, now time.Time) error {
+	if t == nil {
+		return nil
+	}
+	// Unknown versions parse to 0, which is older than any MinVersion.
+	if version, _ := ParseTLSVersion(t.Version); version < p.MinVersion {
+		return fmt.Errorf(
+			"%s is older than %s: %w",
+			t.Version,
+			tlsVersionName(p.MinVersion),
+			ErrTLSPolicy,
+		)
+	}
+	if p.MinValidity > 0 && t.NotAfter.Sub(now) < p.MinValidity {
+		return fmt.Errorf(
+			"certificate expires at %v, within %v: %w",
+			t.NotAfter,
+			p.MinValidity,
+			ErrTLSPolicy,
+		)
+	}
+	if p.RejectSelfSigned && t.SelfSigned {
+		return fmt.Errorf("certificate %q is self-signed: %w", t.Subject, ErrTLSPolicy)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package check
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate generated for tests.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert generates a certificate valid until notAfter,
+// signed by parent, or self-signed when parent is nil.
+func newTestCert(t *testing.T, name string, notAfter time.Time, isCA bool, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if !isCA {
+		template.DNSNames = []string{testHost, "*." + testHost}
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{c.cert.Raw},
+		PrivateKey:  c.key,
+		Leaf:        c.cert,
+	}
+}
+
+func TestCheckTLS(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+	ca := newTestCert(t, "Test CA", time.Now().Add(24*time.Hour*365), true, nil)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	newChecker := func(t *testing.T, leaf *testCert, maxVersion uint16) *Checker {
+		t.Helper()
+		checker := newTestCheckerTLS(t, staticHandler(content), staticHandler(content), &tls.Config{
+			Certificates: []tls.Certificate{leaf.tlsCertificate()},
+			MaxVersion:   maxVersion,
+		})
+		if leaf.cert.Issuer.CommonName == ca.cert.Subject.CommonName {
+			// Otherwise keep trusting the leaf itself.
+			checker.Client.Transport.(*http.Transport).TLSClientConfig.RootCAs = roots
+		}
+		return checker
+	}
+
+	t.Run("report", func(t *testing.T) {
+		leaf := newTestCert(t, testHost, time.Now().Add(24*time.Hour*90), false, ca)
+		checker := newChecker(t, leaf, tls.VersionTLS12)
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+		if err != nil {
+			t.Fatalf("Check returned error: %v", err)
+		}
+		if result.TLS == nil {
+			t.Fatal("Expected TLS to be set")
+		}
+		if result.TLS.Version != "TLS 1.2" {
+			t.Errorf("Expected TLS 1.2, got %q", result.TLS.Version)
+		}
+		if result.TLS.CipherSuite == "" {
+			t.Error("Expected cipher suite to be set")
+		}
+		if result.TLS.Subject != "CN="+testHost || result.TLS.Issuer != "CN=Test CA" {
+			t.Errorf("Unexpected subject %q and issuer %q", result.TLS.Subject, result.TLS.Issuer)
+		}
+		expectedSANs := []string{testHost, "*." + testHost}
+		if !reflect.DeepEqual(result.TLS.SANs, expectedSANs) {
+			t.Errorf("Expected sans %q, got %q", expectedSANs, result.TLS.SANs)
+		}
+		if !result.TLS.NotAfter.Equal(leaf.cert.NotAfter) {
+			t.Errorf("Expected not after %v, got %v", leaf.cert.NotAfter, result.TLS.NotAfter)
+		}
+		expectedChain := []string{"CN=" + testHost, "CN=Test CA"}
+		if !reflect.DeepEqual(result.TLS.Chain, expectedChain) {
+			t.Errorf("Expected chain %q, got %q", expectedChain, result.TLS.Chain)
+		}
+		if result.TLS.SelfSigned {
+			t.Error("Expected not self-signed")
+		}
+	})
+
+	for _, c := range []struct {
+		label      string
+		policy     TLSPolicy
+		leaf       func(t *testing.T) *testCert
+		maxVersion uint16
+		err        error
+	}{
+		{
+			label:      "min-version",
+			policy:     TLSPolicy{MinVersion: tls.VersionTLS13},
+			maxVersion: tls.VersionTLS12,
+			err:        ErrTLSPolicy,
+		},
+		{
+			label:      "min-version-ok",
+			policy:     TLSPolicy{MinVersion: tls.VersionTLS12},
+			maxVersion: tls.VersionTLS12,
+		},
+		{
+			label: "expiring",
+			policy: TLSPolicy{
+				MinValidity: 30 * 24 * time.Hour,
+			},
+			leaf: func(t *testing.T) *testCert {
+				return newTestCert(t, testHost, time.Now().Add(10*24*time.Hour), false, ca)
+			},
+			err: ErrTLSPolicy,
+		},
+		{
+			label: "self-signed",
+			policy: TLSPolicy{
+				RejectSelfSigned: true,
+			},
+			leaf: func(t *testing.T) *testCert {
+				return newTestCert(t, testHost, time.Now().Add(24*time.Hour*90), false, nil)
+			},
+			err: ErrTLSPolicy,
+		},
+		{
+			label: "not-self-signed",
+			policy: TLSPolicy{
+				RejectSelfSigned: true,
+			},
+		},
+	} {
+		t.Run(c.label, func(t *testing.T) {
+			leaf := newTestCert(t, testHost, time.Now().Add(24*time.Hour*90), false, ca)
+			if c.leaf != nil {
+				leaf = c.leaf(t)
+			}
+			checker := newChecker(t, leaf, c.maxVersion)
+			checker.TLSPolicy = c.policy
+			result, err := checker.Check(context.Background(), "http://"+testHost+"/")
+			if !errors.Is(err, c.err) {
+				t.Errorf("Expected error %v, got %v", c.err, err)
+			}
+			if result.TLS == nil {
+				t.Error("Expected TLS to be set")
+			}
+			if c.err != nil && result.HTTPSURL != "" {
+				t.Errorf("Expected no https url, got %q", result.HTTPSURL)
+			}
+		})
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	for s, expected := range map[string]uint16{
+		"":        0,
+		"1.0":     tls.VersionTLS10,
+		"1.2":     tls.VersionTLS12,
+		"TLS 1.3": tls.VersionTLS13,
+	} {
+		version, err := ParseTLSVersion(s)
+		if err != nil {
+			t.Errorf("ParseTLSVersion(%q) returned error: %v", s, err)
+		}
+		if version != expected {
+			t.Errorf("ParseTLSVersion(%q) expected %x, got %x", s, expected, version)
+		}
+	}
+	if _, err := ParseTLSVersion("SSL 3.0"); err == nil {
+		t.Error("Expected error on unknown version")
+	}
+}
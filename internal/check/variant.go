@@ -0,0 +1,84 @@
+package check
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// HostVariant is a strategy to derive another host from the host of the http
+// url, for sites only serving https on one of them.
+type HostVariant string
+
+// Host variants supported by Checker.
+const (
+	// Adds the "www." prefix to the host, e.g. example.com -> www.example.com.
+	HostVariantWWW HostVariant = "www"
+
+	// Removes the "www." prefix from the host,
+	// e.g. www.example.com -> example.com.
+	HostVariantApex HostVariant = "apex"
+)
+
+const wwwPrefix = "www."
+
+// ParseHostVariant parses a HostVariant from its name.
+func ParseHostVariant(s string) (HostVariant, error) {
+	switch v := HostVariant(s); v {
+	case HostVariantWWW, HostVariantApex:
+		return v, nil
+	}
+	return "", fmt.Errorf("unknown host variant %q", s)
+}
+
+// apply returns the variant of host,
+// or false if the variant doesn't apply to host.
+func (v HostVariant) apply(host string) (string, bool) {
+	if net.ParseIP(host) != nil {
+		return "", false
+	}
+	hasWWW := strings.HasPrefix(strings.ToLower(host), wwwPrefix)
+	switch v {
+	case HostVariantWWW:
+		if !hasWWW && strings.Contains(host, ".") {
+			return wwwPrefix + host, true
+		}
+	case HostVariantApex:
+		if hasWWW {
+			return host[len(wwwPrefix):], true
+		}
+	}
+	return "", false
+}
+
+// variantURL is the http url with the host replaced by a variant.
+type variantURL struct {
+	// Empty for the original host.
+	variant HostVariant
+
+	url *url.URL
+}
+
+// hostVariants returns u itself followed by the variants of u that apply,
+// in order and without duplications.
+func hostVariants(u *url.URL, variants []HostVariant) []variantURL {
+	urls := []variantURL{{url: u}}
+	seen := map[string]bool{
+		strings.ToLower(u.Hostname()): true,
+	}
+	for _, v := range variants {
+		host, ok := v.apply(u.Hostname())
+		if !ok || seen[strings.ToLower(host)] {
+			continue
+		}
+		seen[strings.ToLower(host)] = true
+		variant := *u
+		variant.Host = hostWithPort(host, u.Port())
+		urls = append(urls, variantURL{
+			variant: v,
+			url:     &variant,
+		})
+	}
+	return urls
+}
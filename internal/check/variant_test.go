@@ -0,0 +1,126 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestHostVariants(t *testing.T) {
+	both := []HostVariant{HostVariantWWW, HostVariantApex}
+	for _, c := range []struct {
+		url      string
+		variants []HostVariant
+		expected []string
+	}{
+		{
+			url:      "http://example.com/foo",
+			expected: []string{"http://example.com/foo"},
+		},
+		{
+			url:      "http://example.com:8080/foo",
+			variants: both,
+			expected: []string{"http://example.com:8080/foo", "http://www.example.com:8080/foo"},
+		},
+		{
+			url:      "http://WWW.example.com/foo",
+			variants: both,
+			expected: []string{"http://WWW.example.com/foo", "http://example.com/foo"},
+		},
+		{
+			url:      "http://localhost/",
+			variants: both,
+			expected: []string{"http://localhost/"},
+		},
+		{
+			url:      "http://127.0.0.1/",
+			variants: both,
+			expected: []string{"http://127.0.0.1/"},
+		},
+	} {
+		t.Run(c.url, func(t *testing.T) {
+			u, err := url.Parse(c.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var actual []string
+			for _, v := range hostVariants(u, c.variants) {
+				actual = append(actual, v.url.String())
+			}
+			if !reflect.DeepEqual(actual, c.expected) {
+				t.Errorf("Expected %q, got %q", c.expected, actual)
+			}
+		})
+	}
+}
+
+func TestCheckHostVariants(t *testing.T) {
+	const content = "<p>Hello, world!</p>"
+	wwwOnly := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "www."+testHost {
+			http.Error(w, "not here", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(content))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		checker := newTestChecker(t, staticHandler(content), wwwOnly)
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+		if !errors.Is(err, ErrStatus) {
+			t.Errorf("Expected %v, got %v", ErrStatus, err)
+		}
+		if result.Variant != "" {
+			t.Errorf("Expected no variant, got %q", result.Variant)
+		}
+	})
+
+	t.Run("www", func(t *testing.T) {
+		checker := newTestChecker(t, staticHandler(content), wwwOnly)
+		checker.HostVariants = []HostVariant{HostVariantApex, HostVariantWWW}
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+		if err != nil {
+			t.Fatalf("Check returned error: %v", err)
+		}
+		if result.Variant != HostVariantWWW {
+			t.Errorf("Expected variant %q, got %q", HostVariantWWW, result.Variant)
+		}
+		if expected := "https://www." + testHost + "/foo"; result.HTTPSURL != expected {
+			t.Errorf("Expected https url %q, got %q", expected, result.HTTPSURL)
+		}
+	})
+
+	t.Run("similarity-gate", func(t *testing.T) {
+		checker := newTestChecker(t, staticHandler(content), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Host != "www."+testHost {
+				http.Error(w, "not here", http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("<p>Something completely different.</p>"))
+		}))
+		checker.Threshold = 0.95
+		checker.HostVariants = []HostVariant{HostVariantWWW}
+		result, err := checker.Check(context.Background(), "http://"+testHost+"/foo")
+		// The error of the original host is returned.
+		if !errors.Is(err, ErrStatus) {
+			t.Errorf("Expected %v, got %v", ErrStatus, err)
+		}
+		if result.HTTPSURL != "" {
+			t.Errorf("Expected no https url, got %q", result.HTTPSURL)
+		}
+	})
+}
+
+func TestParseHostVariant(t *testing.T) {
+	for _, s := range []string{"www", "apex"} {
+		if v, err := ParseHostVariant(s); err != nil || string(v) != s {
+			t.Errorf("ParseHostVariant(%q) returned %q, %v", s, v, err)
+		}
+	}
+	if _, err := ParseHostVariant("m"); err == nil {
+		t.Error("Expected error on unknown variant")
+	}
+}
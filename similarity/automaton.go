@@ -0,0 +1,246 @@
+package similarity
+
+// automaton is a suffix automaton[1] built on top of a sequence of symbols.
+//
+// It's used to find the longest common substring between the sequence it's
+// built on and another sequence in linear time.
+//
+// The transitions are stored in an open addressing hash table instead of an
+// array per state, so the memory usage is proportional to the number of the
+// transitions instead of the size of the alphabet.
+// All the slices are reused between builds to avoid allocations when it's
+// used repeatedly on smaller and smaller slices.
+//
+// [1]: https://en.wikipedia.org/wiki/Suffix_automaton
+type automaton struct {
+	// Per state data.
+	length []int32 // length of the longest string in the state
+	link   []int32 // suffix link, -1 for the root
+	first  []int32 // end index of the first occurrence of the state's strings
+	head   []int32 // first edge of the state, -1 if none
+
+	// Per edge data, edges of the same state form a linked list.
+	//
+	// They are only used to enumerate the transitions of a state when cloning
+	// it, the targets of the transitions are stored in the hash table.
+	edgeNext []int32
+	edgeSym  []int32
+
+	// Hash table from state and symbol to the target state.
+	slots []slot
+	shift uint
+
+	// When done is closed, build and longest return early with incomplete
+	// results.
+	done <-chan struct{}
+}
+
+// slot is a slot in the hash table.
+//
+// Keys are stored as key+1 so that 0 means empty slot.
+type slot struct {
+	key uint64
+	to  int32
+}
+
+const fibonacciHash = 0x9e3779b97f4a7c15
+
+// doneCheckInterval is the number of steps between every check of done.
+//
+// It must be a power of 2.
+const doneCheckInterval = 1 << 12
+
+// isDone reports whether done is closed,
+// but only checks it at every doneCheckInterval steps.
+func (m *automaton) isDone(step int) bool {
+	if m.done == nil || step&(doneCheckInterval-1) != 0 {
+		return false
+	}
+	select {
+	default:
+		return false
+	case <-m.done:
+		return true
+	}
+}
+
+func (m *automaton) reset(n int) {
+	// A suffix automaton built on n symbols has at most 2n-1 states and at most
+	// 3n-4 transitions.
+	states := 2*n + 1
+	transitions := 3*n + 4
+	slots := 1
+	m.shift = 64
+	for slots < transitions {
+		slots <<= 1
+		m.shift--
+	}
+
+	if cap(m.length) < states {
+		m.length = make([]int32, 0, states)
+		m.link = make([]int32, 0, states)
+		m.first = make([]int32, 0, states)
+		m.head = make([]int32, 0, states)
+	}
+	m.length = m.length[:0]
+	m.link = m.link[:0]
+	m.first = m.first[:0]
+	m.head = m.head[:0]
+
+	if cap(m.edgeNext) < transitions {
+		m.edgeNext = make([]int32, 0, transitions)
+		m.edgeSym = make([]int32, 0, transitions)
+	}
+	m.edgeNext = m.edgeNext[:0]
+	m.edgeSym = m.edgeSym[:0]
+
+	if cap(m.slots) < slots {
+		m.slots = make([]slot, slots)
+	} else {
+		m.slots = m.slots[:slots]
+		for i := range m.slots {
+			m.slots[i] = slot{}
+		}
+	}
+}
+
+func (m *automaton) newState(length, first int32) int32 {
+	m.length = append(m.length, length)
+	m.link = append(m.link, -1)
+	m.first = append(m.first, first)
+	m.head = append(m.head, -1)
+	return int32(len(m.length) - 1)
+}
+
+// lookup returns the index of the hash table slot for the transition from
+// state via sym.
+//
+// The returned slot is either the one holding that transition,
+// or the empty one it should be inserted into.
+func (m *automaton) lookup(state, sym int32) (int, uint64) {
+	key := (uint64(state)<<32 | uint64(uint32(sym))) + 1
+	mask := len(m.slots) - 1
+	i := int((key * fibonacciHash) >> m.shift)
+	for m.slots[i].key != 0 && m.slots[i].key != key {
+		i = (i + 1) & mask
+	}
+	return i, key
+}
+
+// next returns the state the transition from state via sym leads to,
+// or -1 if there's no such transition.
+func (m *automaton) next(state, sym int32) int32 {
+	i, _ := m.lookup(state, sym)
+	if m.slots[i].key == 0 {
+		return -1
+	}
+	return m.slots[i].to
+}
+
+// add adds the transition from state via sym to to,
+// unless there's already a transition from state via sym.
+//
+// It returns the state the existing transition leads to,
+// or -1 if the transition is added.
+func (m *automaton) add(state, sym, to int32) int32 {
+	i, key := m.lookup(state, sym)
+	if m.slots[i].key != 0 {
+		return m.slots[i].to
+	}
+	m.slots[i] = slot{key: key, to: to}
+	m.edgeNext = append(m.edgeNext, m.head[state])
+	m.edgeSym = append(m.edgeSym, sym)
+	m.head[state] = int32(len(m.edgeSym) - 1)
+	return -1
+}
+
+// redirect changes the existing transition from state via sym to lead to to
+// if it currently leads to from.
+//
+// It returns false if the transition doesn't lead to from.
+func (m *automaton) redirect(state, sym, from, to int32) bool {
+	i, _ := m.lookup(state, sym)
+	if m.slots[i].key == 0 || m.slots[i].to != from {
+		return false
+	}
+	m.slots[i].to = to
+	return true
+}
+
+// build (re)builds the automaton on s.
+func (m *automaton) build(s []int32) {
+	m.reset(len(s))
+	last := m.newState(0, -1)
+	for i, c := range s {
+		if m.isDone(i) {
+			return
+		}
+		cur := m.newState(m.length[last]+1, int32(i))
+		p := last
+		last = cur
+		q := int32(-1)
+		for ; p >= 0; p = m.link[p] {
+			if q = m.add(p, c, cur); q >= 0 {
+				break
+			}
+		}
+		if p < 0 {
+			m.link[cur] = 0
+			continue
+		}
+		if m.length[p]+1 == m.length[q] {
+			m.link[cur] = q
+			continue
+		}
+		clone := m.newState(m.length[p]+1, m.first[q])
+		for e := m.head[q]; e >= 0; e = m.edgeNext[e] {
+			m.add(clone, m.edgeSym[e], m.next(q, m.edgeSym[e]))
+		}
+		m.link[clone] = m.link[q]
+		for ; p >= 0 && m.redirect(p, c, q, clone); p = m.link[p] {
+		}
+		m.link[q] = clone
+		m.link[cur] = clone
+	}
+}
+
+// longest finds the longest substring of s that also appears in the string
+// the automaton is built on.
+//
+// It returns the length of the substring,
+// the start index of it inside s,
+// and the start index of its first occurrence inside the built string.
+//
+// When there are multiple substrings of the same longest length,
+// the one with the smallest index inside s wins,
+// unless preferBuilt is true,
+// in which case the one with the smallest index inside the built string wins.
+func (m *automaton) longest(s []int32, preferBuilt bool) (max, indexS, indexBuilt int) {
+	var state, length int32
+	for i, c := range s {
+		if m.isDone(i) {
+			return
+		}
+		for state > 0 && m.next(state, c) < 0 {
+			state = m.link[state]
+			length = m.length[state]
+		}
+		if next := m.next(state, c); next >= 0 {
+			state = next
+			length++
+		} else {
+			length = 0
+		}
+		if length == 0 || int(length) < max {
+			continue
+		}
+		is := i - int(length) + 1
+		ib := int(m.first[state]-length) + 1
+		if int(length) > max || (preferBuilt && ib < indexBuilt) {
+			max = int(length)
+			indexS = is
+			indexBuilt = ib
+		}
+	}
+	return
+}
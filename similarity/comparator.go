@@ -0,0 +1,125 @@
+package similarity
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// A Tokenizer splits content into tokens.
+//
+// The returned tokens are only read by the Comparator,
+// so they can share the underlying array with content.
+type Tokenizer func(content []byte) [][]byte
+
+// Comparator compares contents at the granularity defined by its Tokenizer.
+//
+// When Tokenizer is nil, contents are compared byte by byte,
+// the same as the package level functions.
+// Otherwise contents are first split into tokens,
+// and the similarities are the number of tokens instead of bytes.
+type Comparator struct {
+	// Name of the comparator, used by ComparatorByName.
+	Name string
+
+	Tokenizer Tokenizer
+}
+
+// Predefined comparators.
+var (
+	// Bytes compares contents byte by byte.
+	Bytes = Comparator{
+		Name: "bytes",
+	}
+
+	// Words compares contents word by word, ignoring whitespaces and
+	// punctuations.
+	//
+	// See SplitWords for more details.
+	Words = Comparator{
+		Name:      "words",
+		Tokenizer: SplitWords,
+	}
+
+	// HTML compares contents HTML token by HTML token.
+	//
+	// See SplitHTML for more details.
+	HTML = Comparator{
+		Name:      "html",
+		Tokenizer: SplitHTML,
+	}
+)
+
+// ComparatorByName returns the predefined comparator with the given name.
+//
+// Empty name returns Bytes.
+func ComparatorByName(name string) (Comparator, error) {
+	switch name {
+	case "", Bytes.Name:
+		return Bytes, nil
+	case Words.Name:
+		return Words, nil
+	case HTML.Name:
+		return HTML, nil
+	}
+	return Comparator{}, fmt.Errorf("unknown comparator %q", name)
+}
+
+// Similarity is the Comparator version of SimilarityContext.
+func (c Comparator) Similarity(ctx context.Context, a, b []byte) (int, error) {
+	symbolsA, symbolsB := c.symbols(a, b)
+	return newMatcher(ctx).similarity(symbolsA, symbolsB, nil)
+}
+
+// MinSimilarity is the Comparator version of MinSimilarityContext.
+func (c Comparator) MinSimilarity(ctx context.Context, a, b []byte) (float64, error) {
+	symbolsA, symbolsB := c.symbols(a, b)
+	simA, simB, err := similarity(ctx, symbolsA, symbolsB)
+	return math.Min(simA, simB), err
+}
+
+// MaxSimilarity is the Comparator version of MaxSimilarityContext.
+func (c Comparator) MaxSimilarity(ctx context.Context, a, b []byte) (float64, error) {
+	symbolsA, symbolsB := c.symbols(a, b)
+	simA, simB, err := similarity(ctx, symbolsA, symbolsB)
+	return math.Max(simA, simB), err
+}
+
+// AtLeast is the Comparator version of AtLeastContext.
+func (c Comparator) AtLeast(ctx context.Context, a, b []byte, threshold float64) (bool, error) {
+	symbolsA, symbolsB := c.symbols(a, b)
+	return atLeast(ctx, symbolsA, symbolsB, threshold)
+}
+
+// symbols converts a and b into the symbols the matcher works on.
+//
+// When the Tokenizer is nil, every byte is a symbol.
+// Otherwise every distinct token from a and b is assigned a distinct symbol.
+func (c Comparator) symbols(a, b []byte) ([]int32, []int32) {
+	if c.Tokenizer == nil {
+		return byteSymbols(a), byteSymbols(b)
+	}
+	ids := make(map[string]int32)
+	return tokenSymbols(ids, c.Tokenizer(a)), tokenSymbols(ids, c.Tokenizer(b))
+}
+
+func byteSymbols(content []byte) []int32 {
+	symbols := make([]int32, len(content))
+	for i, c := range content {
+		symbols[i] = int32(c)
+	}
+	return symbols
+}
+
+func tokenSymbols(ids map[string]int32, tokens [][]byte) []int32 {
+	symbols := make([]int32, len(tokens))
+	for i, token := range tokens {
+		id, ok := ids[string(token)]
+		if !ok {
+			id = int32(len(ids))
+			ids[string(token)] = id
+		}
+		symbols[i] = id
+	}
+	return symbols
+}
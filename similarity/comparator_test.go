@@ -0,0 +1,114 @@
+package similarity_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/fishy/https-bot/similarity"
+)
+
+func TestSplitWords(t *testing.T) {
+	tokens := similarity.SplitWords([]byte("Hello, 世界! It's  2021.\n"))
+	expected := []string{"Hello", "世界", "It", "s", "2021"}
+	if actual := toStrings(tokens); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
+
+func TestSplitHTML(t *testing.T) {
+	tokens := similarity.SplitHTML([]byte(
+		`<!DOCTYPE html><P CLASS="foo">Hello, <b>world</b>!</p><!-- bar -->`,
+	))
+	expected := []string{
+		"<!DOCTYPE html>",
+		`<p class="foo">`,
+		"Hello",
+		"<b>",
+		"world",
+		"</b>",
+		"</p>",
+		"<!-- bar -->",
+	}
+	if actual := toStrings(tokens); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %q, got %q", expected, actual)
+	}
+}
+
+func toStrings(tokens [][]byte) []string {
+	s := make([]string, len(tokens))
+	for i, token := range tokens {
+		s[i] = string(token)
+	}
+	return s
+}
+
+func TestComparator(t *testing.T) {
+	for _, c := range []struct {
+		comparator similarity.Comparator
+		a, b       string
+		expected   int
+	}{
+		{
+			comparator: similarity.Bytes,
+			a:          "abcdef",
+			b:          "abcfoodef",
+			expected:   6,
+		},
+		{
+			comparator: similarity.Words,
+			a:          "The quick brown fox jumps over the lazy dog.",
+			b:          "The quick red fox jumps over the lazy dog!",
+			expected:   8,
+		},
+		{
+			// Accidental byte matches between different words don't count.
+			comparator: similarity.Words,
+			a:          "abc def",
+			b:          "abcd ef",
+			expected:   0,
+		},
+		{
+			comparator: similarity.HTML,
+			a:          `<p class="a">Hello world</p>`,
+			b:          `<p class="b">Hello world</p>`,
+			expected:   3,
+		},
+	} {
+		t.Run(fmt.Sprintf("%s-%s-%s", c.comparator.Name, c.a, c.b), func(t *testing.T) {
+			actual, err := c.comparator.Similarity(context.Background(), []byte(c.a), []byte(c.b))
+			if err != nil {
+				t.Fatalf("Similarity returned error: %v", err)
+			}
+			if actual != c.expected {
+				t.Errorf(
+					"Expected %s.Similarity(%q, %q) to return %d, got %d",
+					c.comparator.Name,
+					c.a,
+					c.b,
+					c.expected,
+					actual,
+				)
+			}
+		})
+	}
+}
+
+func TestComparatorByName(t *testing.T) {
+	for _, name := range []string{"", "bytes", "words", "html"} {
+		t.Run(name, func(t *testing.T) {
+			c, err := similarity.ComparatorByName(name)
+			if err != nil {
+				t.Fatalf("ComparatorByName(%q) returned error: %v", name, err)
+			}
+			if name != "" && c.Name != name {
+				t.Errorf("ComparatorByName(%q) returned %q", name, c.Name)
+			}
+		})
+	}
+
+	if _, err := similarity.ComparatorByName("foo"); err == nil {
+		t.Error("Expected error for unknown comparator, got nil")
+	}
+}
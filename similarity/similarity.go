@@ -1,6 +1,7 @@
 package similarity
 
 import (
+	"context"
 	"math"
 )
 
@@ -10,25 +11,125 @@ import (
 // For example, when a is "abcdef" and b is "abcfoodef",
 // they have 2 chunks in common: "abc" and "def", thus 6 is returned.
 func Similarity(a, b []byte) int {
-	common, indexA, indexB := LCS(a, b)
-	if common == 0 || common == len(a) || common == len(b) {
-		return common
+	sim, _ := SimilarityContext(context.Background(), a, b)
+	return sim
+}
+
+// SimilarityContext is the context aware version of Similarity.
+//
+// It checks ctx periodically during the computation,
+// and returns ctx.Err() as soon as ctx is done.
+func SimilarityContext(ctx context.Context, a, b []byte) (int, error) {
+	return Comparator{}.Similarity(ctx, a, b)
+}
+
+// matcher holds the states that can be reused between the LCS calls of a
+// single Similarity call.
+type matcher struct {
+	ctx       context.Context
+	automaton automaton
+	pending   []pair
+}
+
+func newMatcher(ctx context.Context) *matcher {
+	m := &matcher{ctx: ctx}
+	m.automaton.done = ctx.Done()
+	return m
+}
+
+// pair is a pair of chunks from a and b that's not yet compared.
+type pair struct {
+	a, b []int32
+}
+
+// potential returns the max possible similarity of the pair.
+func (p pair) potential() int {
+	if len(p.a) < len(p.b) {
+		return len(p.a)
+	}
+	return len(p.b)
+}
+
+// similarity implements Similarity.
+//
+// Instead of recursion, it keeps the chunks not yet compared in a stack,
+// so that it knows the similarity found so far and the max possible
+// similarity of the remaining chunks at any time.
+// If stop is non-nil, it's called with those two numbers before every LCS
+// call, and the comparison stops early when it returns true.
+func (m *matcher) similarity(a, b []int32, stop func(found, remaining int) bool) (int, error) {
+	var found int
+	first := pair{a: a, b: b}
+	remaining := first.potential()
+	m.pending = append(m.pending[:0], first)
+	for len(m.pending) > 0 {
+		if stop != nil && stop(found, remaining) {
+			break
+		}
+		p := m.pending[len(m.pending)-1]
+		m.pending = m.pending[:len(m.pending)-1]
+		remaining -= p.potential()
+
+		common, indexA, indexB := m.lcs(p.a, p.b)
+		if err := m.ctx.Err(); err != nil {
+			return found, err
+		}
+		found += common
+		if common == 0 || common == len(p.a) || common == len(p.b) {
+			continue
+		}
+		left := pair{
+			a: p.a[0:indexA],
+			b: p.b[0:indexB],
+		}
+		right := pair{
+			a: p.a[common+indexA:],
+			b: p.b[common+indexB:],
+		}
+		remaining += left.potential() + right.potential()
+		m.pending = append(m.pending, right, left)
+	}
+	return found, nil
+}
+
+// bruteForceLimit is the max len(a)*len(b) that we use the brute force LCS
+// implementation instead of building a suffix automaton.
+//
+// For small inputs the overhead of building the automaton is higher than the
+// saved comparisons.
+const bruteForceLimit = 1 << 12
+
+func (m *matcher) lcs(a, b []int32) (max, indexA, indexB int) {
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
+	if len(a)*len(b) <= bruteForceLimit {
+		return lcsBruteForce(a, b)
 	}
-	total := common
-	total += Similarity(a[0:indexA], b[0:indexB])
-	total += Similarity(a[common+indexA:], b[common+indexB:])
-	return total
+	// Build the automaton on the shorter one as building is more expensive
+	// than walking.
+	if len(b) <= len(a) {
+		m.automaton.build(b)
+		return m.automaton.longest(a, false)
+	}
+	m.automaton.build(a)
+	max, indexB, indexA = m.automaton.longest(b, true)
+	return
 }
 
-func similarity(a, b []byte) (float64, float64) {
+func similarity(ctx context.Context, a, b []int32) (float64, float64, error) {
 	if len(a) == 0 && len(b) == 0 {
-		return 1, 1
+		return 1, 1, nil
 	}
 	if len(a) == 0 || len(b) == 0 {
-		return 0, 0
+		return 0, 0, nil
 	}
-	sim := float64(Similarity(a, b))
-	return sim / float64(len(a)), sim / float64(len(b))
+	common, err := newMatcher(ctx).similarity(a, b, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	sim := float64(common)
+	return sim / float64(len(a)), sim / float64(len(b)), nil
 }
 
 // MinSimilarity returns the smaller number between Similarity(a, b) / len(a) and
@@ -36,7 +137,13 @@ func similarity(a, b []byte) (float64, float64) {
 //
 // 1 means they are identical, 0 means they have nothing in common.
 func MinSimilarity(a, b []byte) float64 {
-	return math.Min(similarity(a, b))
+	sim, _ := MinSimilarityContext(context.Background(), a, b)
+	return sim
+}
+
+// MinSimilarityContext is the context aware version of MinSimilarity.
+func MinSimilarityContext(ctx context.Context, a, b []byte) (float64, error) {
+	return Comparator{}.MinSimilarity(ctx, a, b)
 }
 
 // MaxSimilarity returns the larger number between Similarity(a, b) / len(a) and
@@ -45,25 +152,78 @@ func MinSimilarity(a, b []byte) float64 {
 // 1 means either they are identical, or one is superset of the other.
 // (for example, a = "abcdef" and b = "abcfoodef")
 func MaxSimilarity(a, b []byte) float64 {
-	return math.Max(similarity(a, b))
+	sim, _ := MaxSimilarityContext(context.Background(), a, b)
+	return sim
+}
+
+// MaxSimilarityContext is the context aware version of MaxSimilarity.
+func MaxSimilarityContext(ctx context.Context, a, b []byte) (float64, error) {
+	return Comparator{}.MaxSimilarity(ctx, a, b)
+}
+
+// AtLeast reports whether MinSimilarity(a, b) >= threshold.
+//
+// Unlike comparing the return value of MinSimilarity with threshold,
+// AtLeast keeps track of the similarity found so far and the max possible
+// similarity of the chunks not yet compared,
+// and returns as soon as the threshold is provably reached or provably out of
+// reach.
+// As a result it's much faster than MinSimilarity on inputs that are clearly
+// different (or clearly similar).
+func AtLeast(a, b []byte, threshold float64) bool {
+	ok, _ := AtLeastContext(context.Background(), a, b, threshold)
+	return ok
+}
+
+// AtLeastContext is the context aware version of AtLeast.
+func AtLeastContext(ctx context.Context, a, b []byte, threshold float64) (bool, error) {
+	return Comparator{}.AtLeast(ctx, a, b, threshold)
+}
+
+func atLeast(ctx context.Context, a, b []int32, threshold float64) (bool, error) {
+	if len(a) == 0 || len(b) == 0 {
+		simA, simB, err := similarity(ctx, a, b)
+		return math.Min(simA, simB) >= threshold, err
+	}
+	n := float64(len(a))
+	if len(b) > len(a) {
+		n = float64(len(b))
+	}
+	found, err := newMatcher(ctx).similarity(a, b, func(found, remaining int) bool {
+		return float64(found)/n >= threshold || float64(found+remaining)/n < threshold
+	})
+	if err != nil {
+		return false, err
+	}
+	return float64(found)/n >= threshold, nil
 }
 
-// LCS is an implementation of longest common subsequence problem[1] optimized
-// for space.
+// LCS is an implementation of longest common substring problem[1].
 //
-// Most LCS implementations are optimized for time,
-// do a lot of allocations to memoize,
-// making them unsuitable for larger inputs.
+// It returns the length of the longest common substring of a and b,
+// and the start indices of it inside a and b.
+// When there are multiple longest common substrings,
+// the one with the smallest index inside a is returned,
+// with ties broken by the smallest index inside b.
 //
-// Since in our use case we only need the length of the common chunk,
-// we can avoid most of the allocations.
+// Under the hood it builds a suffix automaton[2] on the shorter one of a and b
+// and walks the other one on it,
+// so the time and space complexities are both O(N).
+// Very small inputs are handled by a brute force implementation that doesn't
+// allocate instead.
+//
+// [1]: https://en.wikipedia.org/wiki/Longest_common_substring_problem
+// [2]: https://en.wikipedia.org/wiki/Suffix_automaton
+func LCS(a, b []byte) (max, indexA, indexB int) {
+	return newMatcher(context.Background()).lcs(byteSymbols(a), byteSymbols(b))
+}
+
+// lcsBruteForce is the O(N^2) implementation of LCS that doesn't allocate.
 //
 // In worst case scenario (there's almost nothing in common between a and b)
 // the time complexity is O(N^2).
 // In best case scenario (a == b) the time complexity is O(N).
-//
-// [1]: https://en.wikipedia.org/wiki/Longest_common_subsequence_problem
-func LCS(a, b []byte) (max, indexA, indexB int) {
+func lcsBruteForce(a, b []int32) (max, indexA, indexB int) {
 	for i := 0; i < len(a)-max; i++ {
 		for j := 0; j < len(b)-max; j++ {
 			if a[i] == b[j] {
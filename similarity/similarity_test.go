@@ -1,17 +1,24 @@
 package similarity_test
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	mrand "math/rand"
 	"testing"
 	"testing/quick"
+	"time"
 
 	"github.com/fishy/https-bot/similarity"
 )
 
 var sizes = []int{16, 256, 512, 1024, 5120, 10240}
 
+var largeSizes = []int{1024 * 64, 1024 * 1024}
+
 func TestSimilarity(t *testing.T) {
 	for _, c := range []struct {
 		a, b     string
@@ -174,6 +181,231 @@ func TestSimilarityQuick(t *testing.T) {
 	}
 }
 
+// referenceLCS is the original brute force LCS implementation,
+// used to verify that LCS returns exactly the same results.
+func referenceLCS(a, b []byte) (max, indexA, indexB int) {
+	for i := 0; i < len(a)-max; i++ {
+		for j := 0; j < len(b)-max; j++ {
+			if a[i] == b[j] {
+				k := 1
+				for i+k < len(a) && j+k < len(b) && a[i+k] == b[j+k] {
+					k++
+				}
+				if k > max {
+					max = k
+					indexA = i
+					indexB = j
+				}
+			}
+		}
+	}
+	return
+}
+
+func referenceSimilarity(a, b []byte) int {
+	common, indexA, indexB := referenceLCS(a, b)
+	if common == 0 || common == len(a) || common == len(b) {
+		return common
+	}
+	total := common
+	total += referenceSimilarity(a[0:indexA], b[0:indexB])
+	total += referenceSimilarity(a[common+indexA:], b[common+indexB:])
+	return total
+}
+
+// generateText generates random content with a small alphabet,
+// so that there are a lot of ties between common substrings.
+func generateText(r *mrand.Rand, size, alphabet int) []byte {
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = 'a' + byte(r.Intn(alphabet))
+	}
+	return buf
+}
+
+func TestLCSReference(t *testing.T) {
+	for _, alphabet := range []int{2, 4, 26} {
+		t.Run(fmt.Sprintf("alphabet-%d", alphabet), func(t *testing.T) {
+			f := func(seed int64, sizeA, sizeB uint16) bool {
+				r := mrand.New(mrand.NewSource(seed))
+				a := generateText(r, int(sizeA%1024), alphabet)
+				b := generateText(r, int(sizeB%1024), alphabet)
+				if len(a) > 0 && r.Intn(2) == 0 {
+					// Make sure there are some long common substrings.
+					start := r.Intn(len(a))
+					end := start + r.Intn(len(a)-start+1)
+					b = append(b[:len(b)/2:len(b)/2], append(a[start:end], b[len(b)/2:]...)...)
+				}
+
+				expectedMax, expectedA, expectedB := referenceLCS(a, b)
+				max, indexA, indexB := similarity.LCS(a, b)
+				if max != expectedMax || indexA != expectedA || indexB != expectedB {
+					t.Errorf(
+						"LCS(%q, %q) expected (%d, %d, %d), got (%d, %d, %d)",
+						a,
+						b,
+						expectedMax,
+						expectedA,
+						expectedB,
+						max,
+						indexA,
+						indexB,
+					)
+				}
+
+				expected := referenceSimilarity(a, b)
+				if actual := similarity.Similarity(a, b); actual != expected {
+					t.Errorf(
+						"Similarity(%q, %q) expected %d, got %d",
+						a,
+						b,
+						expected,
+						actual,
+					)
+				}
+				return !t.Failed()
+			}
+			if err := quick.Check(f, nil); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestAtLeast(t *testing.T) {
+	for _, c := range []struct {
+		a, b      string
+		threshold float64
+		expected  bool
+	}{
+		{
+			a:         "",
+			b:         "",
+			threshold: 1,
+			expected:  true,
+		},
+		{
+			a:         "",
+			b:         "foo",
+			threshold: 0.1,
+			expected:  false,
+		},
+		{
+			a:         "abc",
+			b:         "abc",
+			threshold: 1,
+			expected:  true,
+		},
+		{
+			a:         "abcdef",
+			b:         "abcfoodef",
+			threshold: 6.0 / 9,
+			expected:  true,
+		},
+		{
+			a:         "abcdef",
+			b:         "abcfoodef",
+			threshold: 0.67,
+			expected:  false,
+		},
+		{
+			// Rejected by the lengths alone.
+			a:         "abc",
+			b:         "abcdef",
+			threshold: 0.6,
+			expected:  false,
+		},
+	} {
+		t.Run(fmt.Sprintf("%s-%s-%v", c.a, c.b, c.threshold), func(t *testing.T) {
+			actual := similarity.AtLeast([]byte(c.a), []byte(c.b), c.threshold)
+			if actual != c.expected {
+				t.Errorf(
+					"Expected AtLeast(%q, %q, %v) to return %v, got %v",
+					c.a,
+					c.b,
+					c.threshold,
+					c.expected,
+					actual,
+				)
+			}
+		})
+	}
+}
+
+func TestAtLeastQuick(t *testing.T) {
+	f := func(seed int64, sizeA, sizeB uint16, threshold float64) bool {
+		r := mrand.New(mrand.NewSource(seed))
+		a := generateText(r, int(sizeA%1024), 4)
+		b := make([]byte, len(a))
+		copy(b, a)
+		// Randomly mutate b so the similarities spread across the whole range.
+		for i := int(sizeB % 1024); i > 0 && len(b) > 0; i-- {
+			b[r.Intn(len(b))] = 'a' + byte(r.Intn(26))
+		}
+		threshold = math.Mod(math.Abs(threshold), 1)
+
+		sim := similarity.MinSimilarity(a, b)
+		expected := sim >= threshold
+		if actual := similarity.AtLeast(a, b, threshold); actual != expected {
+			t.Errorf(
+				"AtLeast(%q, %q, %v) expected %v (MinSimilarity: %v), got %v",
+				a,
+				b,
+				threshold,
+				expected,
+				sim,
+				actual,
+			)
+		}
+		return !t.Failed()
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSimilarityContext(t *testing.T) {
+	const size = 1024 * 64
+	a := generateContent(t, rand.Reader, size)
+	b := generateContent(t, rand.Reader, size)
+
+	t.Run("background", func(t *testing.T) {
+		sim, err := similarity.SimilarityContext(context.Background(), a, a)
+		if err != nil {
+			t.Fatalf("SimilarityContext returned error: %v", err)
+		}
+		if sim != size {
+			t.Errorf("Expected %d, got %d", size, sim)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := similarity.SimilarityContext(ctx, a, b); !errors.Is(err, context.Canceled) {
+			t.Errorf("SimilarityContext expected %v, got %v", context.Canceled, err)
+		}
+		if _, err := similarity.MinSimilarityContext(ctx, a, b); !errors.Is(err, context.Canceled) {
+			t.Errorf("MinSimilarityContext expected %v, got %v", context.Canceled, err)
+		}
+		if _, err := similarity.AtLeastContext(ctx, a, b, 0.5); !errors.Is(err, context.Canceled) {
+			t.Errorf("AtLeastContext expected %v, got %v", context.Canceled, err)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := similarity.SimilarityContext(ctx, a, b)
+		took := time.Since(start)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected %v, got %v", context.DeadlineExceeded, err)
+		}
+		t.Logf("SimilarityContext took %v", took)
+	})
+}
+
 func BenchmarkSimilarity(b *testing.B) {
 	for _, size := range sizes {
 		b.Run(fmt.Sprintf("size-%d", size), func(b *testing.B) {
@@ -249,3 +481,77 @@ func BenchmarkSimilarity(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkSimilarityLarge(b *testing.B) {
+	for _, size := range largeSizes {
+		b.Run(fmt.Sprintf("size-%d", size), func(b *testing.B) {
+			aa := generateContent(b, rand.Reader, size)
+			bb := generateContent(b, rand.Reader, size)
+
+			b.Run("identical", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					similarity.Similarity(aa, aa)
+				}
+			})
+
+			b.Run("different", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					similarity.Similarity(aa, bb)
+				}
+			})
+
+			b.Run("flip-one", func(b *testing.B) {
+				cc := make([]byte, size)
+				copy(cc, aa)
+				index := size / 2
+				cc[index] = cc[index] ^ 0xff
+				expected := size - 1
+				if actual := similarity.Similarity(aa, cc); actual != expected {
+					b.Fatalf("Expected %d, got %d", expected, actual)
+				}
+				b.ResetTimer()
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					similarity.Similarity(aa, cc)
+				}
+			})
+
+			b.Run("text", func(b *testing.B) {
+				r := mrand.New(mrand.NewSource(int64(size)))
+				a := generateText(r, size, 26)
+				c := generateText(r, size, 26)
+				b.ResetTimer()
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					similarity.Similarity(a, c)
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkAtLeastLarge(b *testing.B) {
+	const threshold = 0.95
+	for _, size := range largeSizes {
+		b.Run(fmt.Sprintf("size-%d", size), func(b *testing.B) {
+			aa := generateContent(b, rand.Reader, size)
+			bb := generateContent(b, rand.Reader, size)
+
+			b.Run("identical", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					similarity.AtLeast(aa, aa, threshold)
+				}
+			})
+
+			b.Run("different", func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					similarity.AtLeast(aa, bb, threshold)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package similarity
+
+import (
+	"bytes"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	_ Tokenizer = SplitWords
+	_ Tokenizer = SplitHTML
+)
+
+// SplitWords is a Tokenizer that splits content into words.
+//
+// A word is a consecutive sequence of unicode letters and numbers,
+// everything else (whitespaces, punctuations, etc.) are separators and
+// dropped.
+func SplitWords(content []byte) [][]byte {
+	return bytes.FieldsFunc(content, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// SplitHTML is a Tokenizer that splits content into HTML tokens.
+//
+// Every tag, comment and doctype is a single token,
+// in its normalized form (for example lower cased tag names and
+// attribute keys).
+// Texts between them are further split into words by SplitWords,
+// so a change to a single word in a long paragraph only makes a difference of
+// a single token.
+//
+// Content that is not valid HTML is tokenized on a best effort basis,
+// the same way browsers do.
+func SplitHTML(content []byte) [][]byte {
+	var tokens [][]byte
+	z := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			// With bytes.Reader the only possible error is io.EOF.
+			return tokens
+		case html.TextToken:
+			// Text returned by z.Text is only valid until the next call to z.Next,
+			// so make a copy of it before splitting.
+			text := append([]byte(nil), z.Text()...)
+			tokens = append(tokens, SplitWords(text)...)
+		default:
+			tokens = append(tokens, []byte(z.Token().String()))
+		}
+	}
+}